@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"upgradebot/config"
+	"upgradebot/pkg/analysis"
+	"upgradebot/pkg/git"
+	"upgradebot/pkg/github"
+	"upgradebot/pkg/markdown"
+)
+
+// runAnalyze - report the analysis of the go-ethereum changes between two arbitrary tags, without pushing or opening a PR
+func runAnalyze(ctx context.Context, args []string) error {
+	flags := newFlagSet("analyze")
+	configPath := configFlag(flags)
+	output := flags.String("output", "", "file to write the report to (default: stdout)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return usageError("expected exactly 2 arguments: <base tag> <target tag>")
+	}
+	baseTag, targetTag := flags.Arg(0), flags.Arg(1)
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	githubAPI := newGithub(cfg)
+	git := newGit(cfg)
+
+	clearRepository, err := cloneQuorumRepository(git)
+	if err != nil {
+		return err
+	}
+	defer clearRepository()
+
+	log.Printf("Analysing Go-Ethereum changes. Base version: %s. Target Version: %s\n", baseTag, targetTag)
+
+	upgrade, err := getAnalyzedReleases(ctx, githubAPI, baseTag, targetTag)
+	if err != nil {
+		return err
+	}
+	analysis, err := analyse(ctx, git, githubAPI, analysis.NewRiskModel(cfg.RiskWeights), cfg.CriticalAreas, baseTag, targetTag)
+	if err != nil {
+		return err
+	}
+
+	if err := writeReport(*output, markdown.CreatePullRequestBody(upgrade, getVersion(git), analysis)); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
+
+// getAnalyzedReleases - releases between the two tags, or only the target release data when the tags are not an upgrade
+// between releases (non-semver tags, tags without a release, target not greater than the base)
+func getAnalyzedReleases(ctx context.Context, githubAPI github.Github, baseTag string, targetTag string) (github.UpgradeReleases, error) {
+	upgrade, err := githubAPI.GetUpgradeReleases(ctx, baseTag, targetTag)
+	if err == nil {
+		return upgrade, nil
+	}
+	if errors.Is(err, context.Canceled) {
+		return github.UpgradeReleases{}, err
+	}
+	log.Printf("Not listing the releases between %s and %s: %v\n", baseTag, targetTag, err)
+
+	target, err := githubAPI.GetGethReleaseData(ctx, targetTag)
+	if errors.Is(err, github.ErrNotFound) {
+		log.Printf("No release for the tag %s, the report has no release notes\n", targetTag)
+		target, err = github.ReleaseData{Name: targetTag, Tag: targetTag}, nil
+	}
+	if err != nil {
+		return github.UpgradeReleases{}, err
+	}
+	return github.UpgradeReleases{Base: github.ReleaseData{Name: baseTag, Tag: baseTag}, Target: target, Releases: []github.ReleaseData{target}}, nil
+}
+
+// analyse - analyse the quorum and go-ethereum changes between two geth tags
+func analyse(ctx context.Context, repo git.Git, githubAPI github.Github, riskModel analysis.RiskModel, criticalAreas []config.CriticalArea, baseTag string, targetTag string) (analysis.Analysis, error) {
+	filesChangedByQuorum, err := repo.GetChangedFilesAgainstGethBaseVersion(baseTag)
+	if err != nil {
+		return analysis.Analysis{}, err
+	}
+	expectedFileConflicts, err := getConflicts(repo, targetTag)
+	if err != nil {
+		return analysis.Analysis{}, err
+	}
+	quorumHunks, upstreamHunks, err := getChangedHunks(repo, baseTag, targetTag)
+	if err != nil {
+		return analysis.Analysis{}, err
+	}
+	goSources, err := getGoSources(repo, baseTag, baseTag, targetTag, upstreamHunks, filesChangedByQuorum)
+	if err != nil {
+		return analysis.Analysis{}, err
+	}
+	goSources.TargetHunks = upstreamHunks
+	tagCompare, err := githubAPI.GetGethTagComparison(ctx, baseTag, targetTag)
+	if err != nil {
+		return analysis.Analysis{}, err
+	}
+	hunks := analysis.UpgradeHunks{Quorum: quorumHunks, Upstream: upstreamHunks, PullRequests: getPullRequestHunks(repo, baseTag, tagCompare)}
+
+	symbolImpacts := analysis.GetSymbolImpacts(goSources)
+	result := analysis.GetAnalysis(tagCompare, filesChangedByQuorum, expectedFileConflicts, hunks, symbolImpacts, riskModel, criticalAreas)
+	result.APIBreakages = analysis.GetAPIBreakages(goSources)
+	return result, nil
+}
+
+// getChangedHunks - hunks changed by Quorum and by go-ethereum from the base to the target tag, both numbered in the base tag
+func getChangedHunks(repo git.Git, baseTag string, targetTag string) (map[string][]git.Hunk, map[string][]git.Hunk, error) {
+	quorumHunks, err := repo.GetChangedHunks(baseTag, "HEAD")
+	if err != nil {
+		return nil, nil, err
+	}
+	upstreamHunks, err := repo.GetChangedHunks(baseTag, targetTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	return quorumHunks, upstreamHunks, nil
+}
+
+// getPullRequestHunks - hunks changed by each PR in its files, from the diff of its merge commit, numbered in the base tag.
+// The PRs whose merge commit is unknown or missing from the clone are left out, so they are assessed per file.
+// For a rebase merge, the merge commit is the last commit of the PR: the hunks are the ones of this commit only.
+func getPullRequestHunks(repo git.Git, baseTag string, tagCompare github.TagCompare) map[int]map[string][]git.Hunk {
+	prHunks := make(map[int]map[string][]git.Hunk)
+	skipped := 0
+	for _, pr := range tagCompare.PullRequests {
+		sha := pr.Data.MergeCommitSha
+		if sha == "" || len(pr.Files) == 0 {
+			skipped++
+			continue
+		}
+		files := make([]string, len(pr.Files))
+		for i, file := range pr.Files {
+			files[i] = file.Filename
+		}
+		mergeHunks, err := repo.GetChangedHunks(sha+"^", sha, files...)
+		if err != nil {
+			skipped++
+			continue
+		}
+		// the parent of the merge commit is numbered in the base through the changes since the base
+		parentHunks, err := repo.GetChangedHunks(baseTag, sha+"^", files...)
+		if err != nil {
+			skipped++
+			continue
+		}
+		prHunks[pr.Data.Number] = make(map[string][]git.Hunk)
+		for file, hunks := range mergeHunks {
+			prHunks[pr.Data.Number][file] = analysis.MapHunksToBase(hunks, parentHunks[file])
+		}
+	}
+	if skipped > 0 {
+		log.Printf("No merge commit diff for %d PR(s), their files are assessed with the hunks of the whole upgrade\n", skipped)
+	}
+	return prHunks
+}
+
+// getGoSources - Go files changed by go-ethereum from the `from` tag to the target tag at both tags, and the ones changed by
+// Quorum too or added by Quorum at HEAD, Quorum having merged the base tag. The hunks are left to the caller.
+func getGoSources(repo git.Git, baseTag string, fromTag string, targetTag string, upstreamHunks map[string][]git.Hunk, filesChangedByQuorum []string) (analysis.SymbolSources, error) {
+	upstreamFiles := make([]string, 0, len(upstreamHunks))
+	for file := range upstreamHunks {
+		upstreamFiles = append(upstreamFiles, file)
+	}
+	upstreamGoFiles := goFiles(upstreamFiles)
+	quorumGoFiles := goFiles(filesChangedByQuorum)
+
+	// the files changed by Quorum are read at the base too, the ones absent from the base are added by Quorum
+	base, err := repo.GetFileContents(baseTag, append(append([]string{}, upstreamGoFiles...), quorumGoFiles...))
+	if err != nil {
+		return analysis.SymbolSources{}, err
+	}
+	from := base
+	if fromTag != baseTag {
+		from, err = repo.GetFileContents(fromTag, upstreamGoFiles)
+		if err != nil {
+			return analysis.SymbolSources{}, err
+		}
+	}
+	target, err := repo.GetFileContents(targetTag, upstreamGoFiles)
+	if err != nil {
+		return analysis.SymbolSources{}, err
+	}
+
+	changedUpstream := make(map[string]bool)
+	for _, file := range upstreamGoFiles {
+		changedUpstream[file] = true
+	}
+	quorumFiles := make([]string, 0)
+	for _, file := range quorumGoFiles {
+		if _, inBase := base[file]; changedUpstream[file] || !inBase {
+			quorumFiles = append(quorumFiles, file)
+		}
+	}
+	quorum, err := repo.GetFileContents("HEAD", quorumFiles)
+	if err != nil {
+		return analysis.SymbolSources{}, err
+	}
+
+	sources := analysis.SymbolSources{Base: make(analysis.GoSources), Target: target, Quorum: make(analysis.GoSources), QuorumOnly: make(analysis.GoSources)}
+	if fromTag != baseTag {
+		sources.QuorumBase = make(analysis.GoSources)
+	}
+	for _, file := range upstreamGoFiles {
+		if content, ok := from[file]; ok {
+			sources.Base[file] = content
+		}
+		if content, ok := base[file]; ok && sources.QuorumBase != nil {
+			sources.QuorumBase[file] = content
+		}
+	}
+	for file, content := range quorum {
+		if changedUpstream[file] {
+			sources.Quorum[file] = content
+		}
+		if _, inBase := base[file]; !inBase {
+			sources.QuorumOnly[file] = content
+		}
+	}
+
+	return sources, nil
+}
+
+// goFiles - the Go files, without the tests
+func goFiles(files []string) []string {
+	filtered := make([]string, 0, len(files))
+	for _, file := range files {
+		if strings.HasSuffix(file, ".go") && !strings.HasSuffix(file, "_test.go") {
+			filtered = append(filtered, file)
+		}
+	}
+	return filtered
+}
+
+// getConflicts - files with conflicts when merging the geth tag into Quorum, none if the git backend cannot detect them
+func getConflicts(repo git.Git, targetTag string) ([]string, error) {
+	conflictDetector, ok := repo.(git.ConflictDetector)
+	if !ok {
+		log.Println("The git backend cannot detect the merge conflicts, the files changed by Quorum are reported as warnings only")
+		return nil, nil
+	}
+	return conflictDetector.GetConflictsFilesAgainstGethTargetVersion(targetTag)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upgradebot/config"
+	"upgradebot/pkg/github/fake"
+)
+
+func TestAnalyze(t *testing.T) {
+	cfg := config.Default()
+	cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", "ghp_testtoken"
+	f, fx := newUpgradeFixture(t, cfg)
+	server := fake.NewServer(fx)
+	defer server.Close()
+	f.Configure(cfg)
+	server.Configure(cfg)
+	reportPath := filepath.Join(f.Dir, "report.md")
+
+	args := []string{"--config", writeConfig(t, f.Dir, cfg), "--output", reportPath, "v1.0.0", "v1.0.1"}
+	if err := commands["analyze"].run(context.Background(), args); err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+
+	report, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"v1.0.0", "notes 1.0.1", "[#101](https://github.com/ethereum/go-ethereum/pull/101)", "core/a.go"} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report has no %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(string(report), "notes 1.0.2") {
+		t.Errorf("report has the notes of a later release:\n%s", report)
+	}
+	quorum := server.Fixture().Repository(cfg.QuorumRepoName)
+	if branches, _ := f.BotBranches(); len(branches) != 0 || len(quorum.PullRequests) != 0 {
+		t.Errorf("bot branches = %v, PRs = %+v, want none", branches, quorum.PullRequests)
+	}
+}
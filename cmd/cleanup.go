@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// runCleanup - remove the local Quorum clone and the upgrade branches of the bot fork that are not used by an open PR
+func runCleanup(ctx context.Context, args []string) error {
+	flags := newFlagSet("cleanup")
+	configPath := configFlag(flags)
+	dryRun := flags.Bool("dry-run", false, "list the stale branches without deleting them")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	githubAPI := newGithub(cfg)
+	git := newGit(cfg)
+
+	if *dryRun {
+		log.Printf("Dry-run, the Quorum repository is not deleted\n")
+	} else if err := git.ClearQuorumRepository(); err != nil {
+		return fmt.Errorf("delete the Quorum repository: %w", err)
+	}
+
+	// any open PR from a branch of the bot fork keeps it, even if its title was edited
+	openPrs, err := githubAPI.GetOpenPullRequests(ctx)
+	if err != nil {
+		return err
+	}
+	openLabels := make(map[string]bool)
+	for _, pr := range openPrs {
+		openLabels[pr.Head.Label] = true
+	}
+
+	branches, err := git.ListBotBranches(upgradeBranchPrefix)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, branch := range branches {
+		if openLabels[cfg.QuorumBotOwner+":"+branch] {
+			continue
+		}
+		if *dryRun {
+			log.Printf("Stale branch %s. Dry-run, not deleted\n", branch)
+			continue
+		}
+		if err := git.DeleteBotBranch(branch); err != nil {
+			log.Printf("Failed to delete stale branch %s: %v\n", branch, err)
+			failed++
+			continue
+		}
+		log.Printf("Deleted stale branch %s\n", branch)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d stale branches", failed)
+	}
+	return nil
+}
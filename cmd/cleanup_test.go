@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"upgradebot/config"
+	"upgradebot/pkg/github"
+	"upgradebot/pkg/github/fake"
+)
+
+func TestCleanup(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// title and owner of the open PR from the v1.0.1-1 branch, by default an upgrade PR from the bot fork
+		title string
+		owner string
+		want  []string
+	}{
+		{
+			name: "stale branches deleted",
+			want: []string{"feature", upgradeBranchPrefix + "v1.0.1-1"},
+		},
+		{
+			name:  "open PR with an edited title",
+			title: "Upgrade to go-ethereum v1.0.1 (WIP)",
+			want:  []string{"feature", upgradeBranchPrefix + "v1.0.1-1"},
+		},
+		{
+			name:  "open PR from a branch of the same name in another fork",
+			owner: "someone",
+			want:  []string{"feature"},
+		},
+		{
+			name: "dry-run",
+			args: []string{"--dry-run"},
+			want: []string{"feature", upgradeBranchPrefix + "v1.0.0-1", upgradeBranchPrefix + "v1.0.1-1", upgradeBranchPrefix + "v1.0.1-2"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", "ghp_testtoken"
+			f, fx := newUpgradeFixture(t, cfg)
+			for branch, tag := range map[string]string{
+				"feature":                        "v1.0.0",
+				upgradeBranchPrefix + "v1.0.0-1": "v1.0.0",
+				upgradeBranchPrefix + "v1.0.1-1": "v1.0.1",
+				upgradeBranchPrefix + "v1.0.1-2": "v1.0.1",
+			} {
+				if err := f.BotBranch(branch, tag); err != nil {
+					t.Fatal(err)
+				}
+			}
+			openBranch := upgradeBranchPrefix + "v1.0.1-1"
+			title, owner := test.title, test.owner
+			if title == "" {
+				title = github.UpgradePullRequestTitle("v1.0.1")
+			}
+			if owner == "" {
+				owner = cfg.QuorumBotOwner
+			}
+			fx.Repository(cfg.QuorumRepoName).PullRequests = []*fake.PullRequest{{
+				Data: github.PullRequestData{Number: 1, Title: title,
+					Head: github.PullRequestHead{Ref: openBranch, Label: owner + ":" + openBranch}},
+				State: "open",
+			}}
+			server := fake.NewServer(fx)
+			defer server.Close()
+			f.Configure(cfg)
+			server.Configure(cfg)
+			if err := os.MkdirAll(cfg.QuorumRepoFolder, 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := commands["cleanup"].run(context.Background(), append([]string{"--config", writeConfig(t, f.Dir, cfg)}, test.args...)); err != nil {
+				t.Fatalf("cleanup: %v", err)
+			}
+
+			branches, err := f.BotBranches()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(branches, test.want) {
+				t.Errorf("bot branches = %v, want %v", branches, test.want)
+			}
+			_, err = os.Stat(cfg.QuorumRepoFolder)
+			if dryRun := len(test.args) > 0; dryRun == os.IsNotExist(err) {
+				t.Errorf("Quorum repository folder: %v, want it deleted unless dry-run", err)
+			}
+		})
+	}
+}
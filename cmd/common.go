@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"upgradebot/config"
+	"upgradebot/pkg/git"
+	"upgradebot/pkg/git/exec"
+	"upgradebot/pkg/git/gogit"
+	"upgradebot/pkg/github"
+	"upgradebot/pkg/github/graphql"
+	"upgradebot/pkg/github/http"
+)
+
+// loadConfig - load and validate the config, before anything is cloned or requested
+func loadConfig(path string) (*config.Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// newGithub - GitHub API client of the configured backend
+func newGithub(cfg *config.Config) github.Github {
+	if cfg.GithubBackend == config.GithubBackendGraphQL {
+		return graphql.NewGithub(cfg)
+	}
+	return http.NewGithub(cfg)
+}
+
+// newGit - git operations of the configured backend
+func newGit(cfg *config.Config) git.Git {
+	if cfg.GitBackend == config.GitBackendGoGit {
+		return gogit.NewGit(cfg)
+	}
+	return exec.NewGit(cfg)
+}
+
+// cloneQuorumRepository - clone the Quorum repository, the returned func deletes it and must be deferred
+func cloneQuorumRepository(git git.Git) (func(), error) {
+	clearRepository := func() {
+		if err := git.ClearQuorumRepository(); err != nil {
+			log.Printf("Failed to delete the Quorum repository: %v\n", err)
+		}
+	}
+	if err := git.CloneQuorumRepository(); err != nil {
+		clearRepository()
+		return nil, err
+	}
+	return clearRepository, nil
+}
+
+// getBaseGethTag - geth version merged into Quorum from the configured source, warning if the other source disagrees
+func getBaseGethTag(cfg *config.Config, git git.Git) (string, error) {
+	source, check := git.GetBaseGethTag, git.GetMergedGethTag
+	if cfg.BaseVersionSource == config.BaseVersionFromHistory {
+		source, check = check, source
+	}
+	baseTag, err := source()
+	if isUnstableVersion(err) {
+		// between two releases, the version file has a development version: the history has the merged release
+		log.Printf("Warning: %v, the Go-Ethereum version is taken from the history\n", err)
+		return check()
+	}
+	if err != nil {
+		return "", err
+	}
+	checkTag, err := check()
+	if err != nil {
+		log.Printf("Warning: could not cross-check the Go-Ethereum version %s: %v\n", baseTag, err)
+	} else if checkTag != baseTag {
+		log.Printf("Warning: the Go-Ethereum version is %s from the %s, but %s from the other source\n", baseTag, cfg.BaseVersionSource, checkTag)
+	}
+	return baseTag, nil
+}
+
+// isUnstableVersion - whether the error is the development version of the version file, not a release
+func isUnstableVersion(err error) bool {
+	return errors.Is(err, git.ErrUnstableVersion)
+}
+
+// getVersion - versions of the Quorum version file, nil if it cannot be parsed as it is only reported
+func getVersion(git git.Git) *git.Version {
+	version, err := git.GetVersion()
+	if err != nil {
+		log.Printf("Warning: could not read the Quorum version: %v\n", err)
+		return nil
+	}
+	return &version
+}
+
+// writeReport - write a report to a file, or to stdout if no file is given
+func writeReport(output string, report string) error {
+	if output == "" {
+		_, err := os.Stdout.WriteString(report)
+		return err
+	}
+	return ioutil.WriteFile(output, []byte(report), 0644)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"upgradebot/config"
+	"upgradebot/pkg/git/gitfixture"
+)
+
+func TestGetBaseGethTag(t *testing.T) {
+	tests := []struct {
+		name        string
+		versionFile string
+		source      string
+	}{
+		{name: "release version file", versionFile: "v1.0.0", source: config.BaseVersionFromFile},
+		{name: "unstable version file", versionFile: "v1.0.1-unstable", source: config.BaseVersionFromFile},
+		{name: "history with an unstable version file", versionFile: "v1.0.1-unstable", source: config.BaseVersionFromHistory},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, err := gitfixture.VersionFile(test.versionFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := gitfixture.NewForkScenario(t, map[string]string{"params/version.go": version})
+			cfg := config.Default()
+			cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", "ghp_testtoken"
+			cfg.BaseVersionSource = test.source
+			f.Configure(cfg)
+			git := newGit(cfg)
+			clearRepository, err := cloneQuorumRepository(git)
+			if err != nil {
+				t.Fatalf("clone: %v", err)
+			}
+			defer clearRepository()
+
+			if baseTag, err := getBaseGethTag(cfg, git); err != nil || baseTag != "v1.0.0" {
+				t.Errorf("getBaseGethTag = %q, %v, want v1.0.0", baseTag, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+
+	"upgradebot/config"
+)
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ContinueOnError)
+}
+
+// configFlag - register the --config flag, the config file path falls back to the UPGRADEBOT_CONFIG env var
+func configFlag(flags *flag.FlagSet) *string {
+	return flags.String("config", "", "YAML config file (default: $"+config.EnvConfigFile+", or the built-in defaults)")
+}
+
+// parseFlags - parse the subcommand flags, turning invalid flags into a usageError
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return usageError(err.Error())
+	}
+	return nil
+}
@@ -1,76 +1,98 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"strings"
-	"time"
-
-	"upgradebot/config"
-	"upgradebot/pkg/analysis"
-	"upgradebot/pkg/git"
-	"upgradebot/pkg/github/http"
-	"upgradebot/pkg/markdown"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
 )
 
-func main() {
-	log.Println("Gather information from Go-Ethereum release to prepare an upstream upgrade")
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
 
-	cfg := config.GetConfig()
-	githubAPI := http.NewGithub(cfg)
-	git := git.NewGit(cfg)
+// command - a subcommand of the bot, run with the arguments following its name
+type command struct {
+	description string
+	run         func(ctx context.Context, args []string) error
+}
 
-	git.CloneQuorumRepository()
-	defer git.ClearQuorumRepository()
+var commands = map[string]command{
+	"upgrade": {description: "open a PR upgrading Quorum to the next Go-Ethereum release, or to upgrade --target <tag>", run: runUpgrade},
+	"analyze": {description: "report the analysis between two Go-Ethereum tags: analyze <base> <target>", run: runAnalyze},
+	"status":  {description: "show the Go-Ethereum version merged into Quorum and the next release", run: runStatus},
+	"roadmap": {description: "report every Go-Ethereum release up to the latest one, to plan the upgrades", run: runRoadmap},
+	"cleanup": {description: "remove the local Quorum clone and the stale upgrade branches of the bot fork", run: runCleanup},
+}
 
-	baseTag := git.GetBaseGethTag()
-	releaseData := githubAPI.GetNextReleaseFrom(baseTag)
-	targetTag := releaseData.Tag
+func main() {
+	ctx, cancel := interruptibleContext()
+	code := execute(ctx, os.Args[1:])
+	cancel()
+	os.Exit(code)
+}
 
-	// Validate if we are already in the latest go-ethereum version
-	if baseTag == targetTag {
-		log.Printf("We are already in the latest version %s. Ignore\n", baseTag)
-		return
+// execute - run the command named by the first argument with the following ones, and return the exit code
+func execute(ctx context.Context, args []string) int {
+	if len(args) < 1 {
+		usage()
+		return exitUsage
 	}
 
-	// Validate if we don't have any PR already opened for an upgrade of the new version
-	openPr := githubAPI.FindOpenUpgradePullRequest(targetTag)
-	if openPr != nil {
-		log.Printf("There is already a PR on %s. Ignore\n", openPr.HtmlUrl)
-		return
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		usage()
+		return exitUsage
 	}
 
-	log.Printf("Preparing release PR. Base version: %s. Target Version: %s\n", baseTag, targetTag)
+	if err := cmd.run(ctx, args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		if _, ok := err.(usageError); ok {
+			return exitUsage
+		}
+		return exitError
+	}
+	return exitOK
+}
 
-	// Analyse the quorum and go-ethereum changes to provide an overview of new features and PRs
-	filesChangedByQuorum := git.GetChangedFilesAgainstGethBaseVersion(baseTag)
-	expectedFileConflicts := git.GetConflictsFilesAgainstGethTargetVersion(targetTag)
-	tagCompare := githubAPI.GetGethTagComparison(baseTag, targetTag)
-	analysis := analysis.GetAnalysis(tagCompare, filesChangedByQuorum, expectedFileConflicts)
+// interruptibleContext - context cancelled on SIGINT or SIGTERM, so that the running command stops and cleans up
+func interruptibleContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
 
-	// Create PR body
-	builder := strings.Builder{}
-	builder.WriteString(markdown.CreateMarkdownHeader())
-	builder.WriteString("\n\n")
-	builder.WriteString(markdown.CreateMarkdownReleaseSection(releaseData))
-	builder.WriteString("\n\n")
-	builder.WriteString(markdown.CreateMarkdownAnalysisSection(analysis))
-	builder.WriteString("\n\n")
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
 
-	// Create new branch and the  upgrade PR
-	branchName := fmt.Sprintf("upgrade/go-ethereum/%s-%s", targetTag, time.Now().Format("2006102150405"))
-	git.CreateBranchFromGethTag(targetTag, branchName)
-	createdPr, err := githubAPI.CreateQuorumPullRequest(branchName, releaseData, builder.String())
-	if err != nil {
-		log.Fatalf("create PR: %v", err)
-		return
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
-	if createdPr == nil {
-		log.Fatalf("create PR: response is nil")
-		return
-	}
-	if cfg.GithubLabel != "" {
-		_ = githubAPI.AddLabelsToIssue(createdPr.Number, cfg.GithubLabel)
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", name, commands[name].description)
 	}
-	log.Println("Done, PR: " + createdPr.HtmlUrl)
+}
+
+// usageError - error caused by invalid arguments, exits with exitUsage
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
 }
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExecuteUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no command", args: []string{}},
+		{name: "unknown command", args: []string{"downgrade"}},
+		{name: "unknown flag", args: []string{"status", "--verbose"}},
+		{name: "missing argument", args: []string{"analyze", "v1.0.0"}},
+		{name: "extra argument", args: []string{"analyze", "v1.0.0", "v1.0.1", "v1.0.2"}},
+		{name: "negative maximum", args: []string{"roadmap", "--max-releases", "-1"}},
+		{name: "output without dry run", args: []string{"upgrade", "--output", "report.md"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if code := execute(context.Background(), test.args); code != exitUsage {
+				t.Errorf("exit code = %d, want %d", code, exitUsage)
+			}
+		})
+	}
+}
+
+func TestUsageError(t *testing.T) {
+	err := commands["analyze"].run(context.Background(), []string{"v1.0.0"})
+	if _, ok := err.(usageError); !ok {
+		t.Errorf("analyze with one argument = %v, want a usageError", err)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"upgradebot/config"
+	"upgradebot/pkg/analysis"
+	"upgradebot/pkg/git"
+	"upgradebot/pkg/github"
+	"upgradebot/pkg/markdown"
+)
+
+// runRoadmap - report the analysis of every release between the go-ethereum version merged into Quorum and the latest release,
+// to plan which upgrades to batch together
+func runRoadmap(ctx context.Context, args []string) error {
+	flags := newFlagSet("roadmap")
+	configPath := configFlag(flags)
+	output := flags.String("output", "", "file to write the report to (default: stdout)")
+	target := flags.String("target", "", "last go-ethereum tag of the roadmap (default: the latest release)")
+	maxReleases := flags.Int("max-releases", 0, "maximum number of releases to analyse, each costs GitHub requests (default: no limit)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *maxReleases < 0 {
+		return usageError("--max-releases must not be negative")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	// the roadmap goes to the latest release, whatever the policy of the upgrades
+	cfg.UpgradePolicy = config.UpgradePolicyLatest
+	githubAPI := newGithub(cfg)
+	git := newGit(cfg)
+
+	clearRepository, err := cloneQuorumRepository(git)
+	if err != nil {
+		return err
+	}
+	defer clearRepository()
+
+	baseTag, err := getBaseGethTag(cfg, git)
+	if err != nil {
+		return err
+	}
+	upgrade, err := githubAPI.GetUpgradeReleases(ctx, baseTag, *target)
+	if err != nil {
+		return err
+	}
+	releases := upgrade.Releases
+	if *maxReleases > 0 && len(releases) > *maxReleases {
+		releases = releases[:*maxReleases]
+	}
+
+	// the files changed by Quorum are the same for every release, as Quorum stays on the base
+	filesChangedByQuorum, err := git.GetChangedFilesAgainstGethBaseVersion(baseTag)
+	if err != nil {
+		return err
+	}
+
+	riskModel := analysis.NewRiskModel(cfg.RiskWeights)
+	steps := make([]analysis.RoadmapStep, 0, len(releases))
+	previousTag := baseTag
+	for i, release := range releases {
+		log.Printf("Analysing Go-Ethereum release %d/%d: %s to %s\n", i+1, len(releases), previousTag, release.Tag)
+
+		releaseAnalysis, expectedFileConflicts, err := analyseRoadmapStep(ctx, git, githubAPI, riskModel, cfg.CriticalAreas, baseTag, previousTag, release.Tag, filesChangedByQuorum)
+		if err != nil {
+			return err
+		}
+		steps = append(steps, analysis.GetRoadmapStep(release, releaseAnalysis, expectedFileConflicts))
+		previousTag = release.Tag
+	}
+
+	if err := writeReport(*output, markdown.CreateRoadmapReport(baseTag, steps)); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	return nil
+}
+
+// analyseRoadmapStep - analyse the changes from the previous release to a release, and the conflicts of merging the release into Quorum
+func analyseRoadmapStep(ctx context.Context, repo git.Git, githubAPI github.Github, riskModel analysis.RiskModel, criticalAreas []config.CriticalArea, baseTag string, previousTag string, releaseTag string, filesChangedByQuorum []string) (analysis.Analysis, []string, error) {
+	expectedFileConflicts, err := getConflicts(repo, releaseTag)
+	if err != nil {
+		return analysis.Analysis{}, nil, err
+	}
+	// compare with the previous release only, so that each PR is fetched once over the whole roadmap
+	tagCompare, err := githubAPI.GetGethTagComparison(ctx, previousTag, releaseTag)
+	if err != nil {
+		return analysis.Analysis{}, nil, err
+	}
+	hunks, goSources, err := getRoadmapStepSources(repo, baseTag, previousTag, releaseTag, filesChangedByQuorum)
+	if err != nil {
+		return analysis.Analysis{}, nil, err
+	}
+	hunks.PullRequests = getPullRequestHunks(repo, baseTag, tagCompare)
+	symbolImpacts := analysis.GetSymbolImpacts(goSources)
+	return analysis.GetAnalysis(tagCompare, filesChangedByQuorum, expectedFileConflicts, hunks, symbolImpacts, riskModel, criticalAreas), expectedFileConflicts, nil
+}
+
+// getRoadmapStepSources - hunks and Go files of the changes from the previous release to a release, numbered in the base tag
+// merged into Quorum like the hunks of Quorum
+func getRoadmapStepSources(repo git.Git, baseTag string, previousTag string, releaseTag string, filesChangedByQuorum []string) (analysis.UpgradeHunks, analysis.SymbolSources, error) {
+	quorumHunks, releaseHunks, err := getChangedHunks(repo, baseTag, releaseTag)
+	if err != nil {
+		return analysis.UpgradeHunks{}, analysis.SymbolSources{}, err
+	}
+	stepHunks, previousHunks := releaseHunks, map[string][]git.Hunk(nil)
+	if previousTag != baseTag {
+		if stepHunks, err = repo.GetChangedHunks(previousTag, releaseTag); err != nil {
+			return analysis.UpgradeHunks{}, analysis.SymbolSources{}, err
+		}
+		if previousHunks, err = repo.GetChangedHunks(baseTag, previousTag); err != nil {
+			return analysis.UpgradeHunks{}, analysis.SymbolSources{}, err
+		}
+	}
+
+	hunks := analysis.UpgradeHunks{Quorum: quorumHunks, Upstream: make(map[string][]git.Hunk)}
+	for file, fileHunks := range stepHunks {
+		hunks.Upstream[file] = analysis.MapHunksToBase(fileHunks, previousHunks[file])
+	}
+	goSources, err := getGoSources(repo, baseTag, previousTag, releaseTag, stepHunks, filesChangedByQuorum)
+	if err != nil {
+		return analysis.UpgradeHunks{}, analysis.SymbolSources{}, err
+	}
+	goSources.BaseHunks = previousHunks
+	goSources.TargetHunks = releaseHunks
+	return hunks, goSources, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"upgradebot/config"
+	"upgradebot/pkg/analysis"
+	"upgradebot/pkg/github/fake"
+)
+
+func TestRoadmap(t *testing.T) {
+	// Quorum changed the lines of core/a.go changed by v1.0.1, v1.0.2 only changes eth/c.go
+	rowV101 := "| v1.0.1 |  | 1 | 1 | 1 | 1 | 1 | 1 |"
+	rowV102 := "| v1.0.2 |  | 1 | 1 | 0 | 0 | 0 | 1 |"
+	tests := []struct {
+		name     string
+		args     []string
+		want     []string
+		unwanted []string
+	}{
+		{name: "up to the latest release", want: []string{rowV101, rowV102}},
+		{name: "maximum of releases", args: []string{"--max-releases", "1"}, want: []string{rowV101}, unwanted: []string{"v1.0.2"}},
+		{name: "target", args: []string{"--target", "v1.0.1"}, want: []string{rowV101}, unwanted: []string{"v1.0.2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", "ghp_testtoken"
+			f, fx := newUpgradeFixture(t, cfg)
+			server := fake.NewServer(fx)
+			defer server.Close()
+			f.Configure(cfg)
+			server.Configure(cfg)
+			reportPath := filepath.Join(f.Dir, "roadmap.md")
+
+			args := append([]string{"--config", writeConfig(t, f.Dir, cfg), "--output", reportPath}, test.args...)
+			if err := commands["roadmap"].run(context.Background(), args); err != nil {
+				t.Fatalf("roadmap: %v", err)
+			}
+
+			report, err := ioutil.ReadFile(reportPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(report), "roadmap from v1.0.0") {
+				t.Errorf("report has no base:\n%s", report)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(string(report), want) {
+					t.Errorf("report has no %q:\n%s", want, report)
+				}
+			}
+			for _, unwanted := range test.unwanted {
+				if strings.Contains(string(report), unwanted) {
+					t.Errorf("report has %q:\n%s", unwanted, report)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyseRoadmapStep(t *testing.T) {
+	cfg := config.Default()
+	cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", "ghp_testtoken"
+	f, fx := newUpgradeFixture(t, cfg)
+	server := fake.NewServer(fx)
+	defer server.Close()
+	f.Configure(cfg)
+	server.Configure(cfg)
+	repo := newGit(cfg)
+	clearRepository, err := cloneQuorumRepository(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clearRepository()
+	filesChangedByQuorum, err := repo.GetChangedFilesAgainstGethBaseVersion("v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		previousTag    string
+		releaseTag     string
+		wantConflicts  []string
+		wantPR         int
+		wantAssessment analysis.Assessment
+	}{
+		{previousTag: "v1.0.0", releaseTag: "v1.0.1", wantConflicts: []string{"core/a.go"}, wantPR: 101, wantAssessment: analysis.Conflict},
+		// the conflict of v1.0.1 is still there when merging v1.0.2, but not in the changes of v1.0.2
+		{previousTag: "v1.0.1", releaseTag: "v1.0.2", wantConflicts: []string{"core/a.go"}, wantPR: 102, wantAssessment: analysis.Good},
+	}
+	risks := make([]float64, len(tests))
+	for i, test := range tests {
+		stepAnalysis, conflicts, err := analyseRoadmapStep(context.Background(), repo, newGithub(cfg), analysis.NewRiskModel(cfg.RiskWeights),
+			cfg.CriticalAreas, "v1.0.0", test.previousTag, test.releaseTag, filesChangedByQuorum)
+		if err != nil {
+			t.Fatalf("analyseRoadmapStep(%s): %v", test.releaseTag, err)
+		}
+		if !reflect.DeepEqual(conflicts, test.wantConflicts) {
+			t.Errorf("conflicts of %s = %v, want %v", test.releaseTag, conflicts, test.wantConflicts)
+		}
+		if len(stepAnalysis.PrStats) != 1 || stepAnalysis.PrStats[0].Data.Number != test.wantPR || stepAnalysis.PrStats[0].Assessment != test.wantAssessment {
+			t.Fatalf("PRs of %s = %+v, want #%d %s", test.releaseTag, stepAnalysis.PrStats, test.wantPR, test.wantAssessment)
+		}
+		risks[i] = stepAnalysis.PrStats[0].Risk
+	}
+	if risks[0] <= risks[1] {
+		t.Errorf("risk of the conflicting PR = %.1f, want more than %.1f", risks[0], risks[1])
+	}
+}
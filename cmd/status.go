@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// runStatus - show the go-ethereum version merged into Quorum, the next release and its upgrade PR if any
+func runStatus(ctx context.Context, args []string) error {
+	flags := newFlagSet("status")
+	configPath := configFlag(flags)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	githubAPI := newGithub(cfg)
+	git := newGit(cfg)
+
+	clearRepository, err := cloneQuorumRepository(git)
+	if err != nil {
+		return err
+	}
+	defer clearRepository()
+
+	baseTag, err := getBaseGethTag(cfg, git)
+	if err != nil {
+		return err
+	}
+	upgrade, err := githubAPI.GetUpgradeReleases(ctx, baseTag, "")
+	if err != nil {
+		return err
+	}
+	releaseData := upgrade.Target
+
+	if version := getVersion(git); version != nil && version.HasQuorumVersion {
+		fmt.Printf("Quorum version: %s\n", version.QuorumVersion())
+	}
+	fmt.Printf("Quorum Go-Ethereum version: %s\n", baseTag)
+	if releaseData.Tag == baseTag {
+		fmt.Println("Next release: none, already in the latest version")
+		return nil
+	}
+	fmt.Printf("Next release: %s (published %s, %s policy, %d release(s) to upgrade)\n", releaseData.Tag, releaseData.PublishedAt, cfg.UpgradePolicy, len(upgrade.Releases))
+
+	openPr, err := githubAPI.FindOpenUpgradePullRequest(ctx, releaseData.Tag)
+	if err != nil {
+		return err
+	}
+	if openPr != nil {
+		fmt.Printf("Upgrade PR: %s\n", openPr.HtmlUrl)
+	} else {
+		fmt.Println("Upgrade PR: none")
+	}
+	return nil
+}
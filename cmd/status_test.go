@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"upgradebot/config"
+	"upgradebot/pkg/github/fake"
+)
+
+// captureStdout - output of fn on stdout
+func captureStdout(t *testing.T, fn func()) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	writer.Close()
+	output, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output)
+}
+
+func TestStatus(t *testing.T) {
+	cfg := config.Default()
+	cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", "ghp_testtoken"
+	f, fx := newUpgradeFixture(t, cfg)
+	if err := f.MergeUpstream("v1.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	server := fake.NewServer(fx)
+	defer server.Close()
+	f.Configure(cfg)
+	server.Configure(cfg)
+	configPath := writeConfig(t, f.Dir, cfg)
+
+	var err error
+	output := captureStdout(t, func() {
+		err = commands["status"].run(context.Background(), []string{"--config", configPath})
+	})
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	for _, want := range []string{"Quorum Go-Ethereum version: v1.0.1", "Next release: v1.0.2", "Upgrade PR: none"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("status has no %q:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"upgradebot/pkg/analysis"
+	"upgradebot/pkg/github"
+	"upgradebot/pkg/markdown"
+)
+
+const upgradeBranchPrefix = "upgrade/go-ethereum/"
+
+// runUpgrade - open a draft PR in Quorum upgrading to the go-ethereum release chosen by the target or the upgrade policy
+func runUpgrade(ctx context.Context, args []string) error {
+	flags := newFlagSet("upgrade")
+	configPath := configFlag(flags)
+	dryRun := flags.Bool("dry-run", false, "produce the upgrade report without pushing a branch or opening a PR")
+	output := flags.String("output", "", "file to write the dry-run report to (default: stdout)")
+	target := flags.String("target", "", "go-ethereum tag to upgrade to (default: chosen by the upgradePolicy of the config)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *output != "" && !*dryRun {
+		return usageError("--output requires --dry-run")
+	}
+
+	log.Println("Gather information from Go-Ethereum release to prepare an upstream upgrade")
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	githubAPI := newGithub(cfg)
+	git := newGit(cfg)
+
+	clearRepository, err := cloneQuorumRepository(git)
+	if err != nil {
+		return err
+	}
+	defer clearRepository()
+
+	baseTag, err := getBaseGethTag(cfg, git)
+	if err != nil {
+		return err
+	}
+	upgrade, err := githubAPI.GetUpgradeReleases(ctx, baseTag, *target)
+	if err != nil {
+		return err
+	}
+	targetTag := upgrade.Target.Tag
+
+	// Validate if we are already in the latest go-ethereum version
+	if upgrade.IsUpToDate() {
+		log.Printf("We are already in the latest version %s. Ignore\n", baseTag)
+		return nil
+	}
+
+	// Validate if we don't have any PR already opened for an upgrade of the new version
+	openPr, err := githubAPI.FindOpenUpgradePullRequest(ctx, targetTag)
+	if err != nil {
+		return err
+	}
+	if openPr != nil {
+		if !*dryRun {
+			log.Printf("There is already a PR on %s. Ignore\n", openPr.HtmlUrl)
+			return nil
+		}
+		log.Printf("There is already a PR on %s. Dry-run, continue\n", openPr.HtmlUrl)
+	}
+
+	log.Printf("Preparing release PR. Base version: %s. Target Version: %s. Releases: %d\n", baseTag, targetTag, len(upgrade.Releases))
+
+	// Analyse the quorum and go-ethereum changes to provide an overview of new features and PRs
+	analysis, err := analyse(ctx, git, githubAPI, analysis.NewRiskModel(cfg.RiskWeights), cfg.CriticalAreas, baseTag, targetTag)
+	if err != nil {
+		return err
+	}
+
+	// Create PR body
+	prBody := markdown.CreatePullRequestBody(upgrade, getVersion(git), analysis)
+
+	branchName := fmt.Sprintf("%s%s-%s", upgradeBranchPrefix, targetTag, time.Now().Format("2006102150405"))
+
+	// In dry-run mode, only output what would have been pushed and opened
+	if *dryRun {
+		title := github.UpgradePullRequestTitle(targetTag)
+		if err := writeReport(*output, createDryRunReport(branchName, title, prBody)); err != nil {
+			return fmt.Errorf("write dry-run report: %w", err)
+		}
+		log.Println("Done, dry-run: no branch pushed and no PR created")
+		return nil
+	}
+
+	// Create new branch and the  upgrade PR
+	if err := git.CreateBranchFromGethTag(targetTag, branchName); err != nil {
+		return err
+	}
+	createdPr, err := githubAPI.CreateQuorumPullRequest(ctx, branchName, upgrade.Target, prBody)
+	if err != nil {
+		return fmt.Errorf("create PR: %w", err)
+	}
+	if createdPr == nil {
+		return errors.New("create PR: response is nil")
+	}
+	if cfg.GithubLabel != "" {
+		if _, err := githubAPI.AddLabelsToIssue(ctx, createdPr.Number, cfg.GithubLabel); err != nil {
+			log.Printf("Failed to label PR %s: %v\n", createdPr.HtmlUrl, err)
+		}
+	}
+	log.Println("Done, PR: " + createdPr.HtmlUrl)
+	return nil
+}
+
+// createDryRunReport - report of the planned branch, PR title and PR body
+func createDryRunReport(branchName string, title string, body string) string {
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "Branch: %s\n", branchName)
+	fmt.Fprintf(&builder, "Title: %s\n\n", title)
+	builder.WriteString(body)
+	return builder.String()
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+
+	"upgradebot/config"
+	"upgradebot/pkg/git/gitfixture"
+	"upgradebot/pkg/github"
+	"upgradebot/pkg/github/fake"
+)
+
+// newUpgradeFixture - upstream releases v1.0.0 to v1.0.2 and a Quorum fork of v1.0.0 changing core/a.go,
+// with the geth releases, the comparisons of each release with the previous one and the PRs of the upstream commits in the fake GitHub
+func newUpgradeFixture(t *testing.T, cfg *config.Config) (*gitfixture.Fixture, *fake.Fixture) {
+	f := gitfixture.NewFork(t, []gitfixture.Release{
+		{Tag: "v1.0.0", Files: map[string]string{
+			"core/a.go": "package core\n\nfunc A() int {\n\treturn 1\n}\n",
+			"eth/c.go":  "package eth\n",
+		}},
+		{Tag: "v1.0.1", Files: map[string]string{"core/a.go": "package core\n\nfunc A() int {\n\treturn 2\n}\n"}},
+		{Tag: "v1.0.2", Files: map[string]string{"eth/c.go": "package eth\n\n// C - c\n"}},
+	}, map[string]string{
+		"core/a.go":  "package core\n\nfunc A() int {\n\treturn private()\n}\n",
+		"private.go": "package core\n\nfunc private() int { return 0 }\n",
+	})
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	shaV101, err := f.UpstreamSha("v1.0.1")
+	must(err)
+	shaV102, err := f.UpstreamSha("v1.0.2")
+	must(err)
+
+	fx := fake.NewFixture()
+	geth := fx.Repository(cfg.GethRepoName)
+	geth.Releases = []github.ReleaseData{
+		{Name: "Release 1.0.2", Tag: "v1.0.2", Body: "notes 1.0.2"},
+		{Name: "Release 1.0.1", Tag: "v1.0.1", Body: "notes 1.0.1"},
+		{Name: "Release 1.0.0", Tag: "v1.0.0"},
+	}
+	fileA := github.File{Filename: "core/a.go", Status: "modified", Additions: 1, Deletions: 1, Changes: 2}
+	geth.Compares["v1.0.0...v1.0.1"] = github.CommitChanges{
+		Commits: []github.Commit{{Sha: shaV101, Commit: github.CommitDetails{Message: "core: return 2 (#101)"}}},
+		Files:   []github.File{fileA},
+	}
+	geth.Compares["v1.0.1...v1.0.2"] = github.CommitChanges{
+		Commits: []github.Commit{{Sha: shaV102, Commit: github.CommitDetails{Message: "eth: document C (#102)"}}},
+		Files:   []github.File{{Filename: "eth/c.go", Status: "modified", Additions: 2, Changes: 2}},
+	}
+	geth.AddMergedPullRequest(github.PullRequestData{Number: 101, Title: "core: return 2", MergeCommitSha: shaV101,
+		HtmlUrl: "https://github.com/ethereum/go-ethereum/pull/101"}, []github.File{fileA}, shaV101)
+	geth.AddMergedPullRequest(github.PullRequestData{Number: 102, Title: "eth: document C", MergeCommitSha: shaV102,
+		HtmlUrl: "https://github.com/ethereum/go-ethereum/pull/102"},
+		[]github.File{{Filename: "eth/c.go", Status: "modified", Additions: 2, Changes: 2}}, shaV102)
+	fx.Repository(cfg.QuorumRepoName).ExistingLabels = []string{cfg.GithubLabel}
+	return f, fx
+}
+
+// writeConfig - write the config to a YAML file in dir
+func writeConfig(t *testing.T, dir string, cfg *config.Config) string {
+	content, err := yaml.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yml")
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUpgrade(t *testing.T) {
+	tests := []struct {
+		githubBackend string
+		gitBackend    string
+	}{
+		{githubBackend: config.GithubBackendREST, gitBackend: config.GitBackendExec},
+		{githubBackend: config.GithubBackendGraphQL, gitBackend: config.GitBackendGoGit},
+	}
+	for _, test := range tests {
+		t.Run(test.githubBackend+"/"+test.gitBackend, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.GithubBackend = test.githubBackend
+			cfg.GitBackend = test.gitBackend
+			cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", "ghp_testtoken"
+			f, fx := newUpgradeFixture(t, cfg)
+			server := fake.NewServer(fx)
+			defer server.Close()
+			f.Configure(cfg)
+			server.Configure(cfg)
+			configPath := writeConfig(t, f.Dir, cfg)
+
+			if err := commands["upgrade"].run(context.Background(), []string{"--config", configPath}); err != nil {
+				t.Fatalf("upgrade: %v", err)
+			}
+
+			branches, err := f.BotBranches()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(branches) != 1 || !strings.HasPrefix(branches[0], upgradeBranchPrefix+"v1.0.1-") {
+				t.Fatalf("bot branches = %v, want one %sv1.0.1 branch", branches, upgradeBranchPrefix)
+			}
+
+			quorum := server.Fixture().Repository(cfg.QuorumRepoName)
+			if len(quorum.PullRequests) != 1 {
+				t.Fatalf("PRs = %+v, want one PR", quorum.PullRequests)
+			}
+			pr := quorum.PullRequests[0]
+			if pr.Data.Title != github.UpgradePullRequestTitle("v1.0.1") || !pr.Draft || pr.State != "open" ||
+				pr.Base != cfg.QuorumBaseBranch || pr.Data.Head.Label != cfg.QuorumBotOwner+":"+branches[0] {
+				t.Errorf("PR = %+v", pr)
+			}
+			for _, want := range []string{"v1.0.0", "v1.0.1", "notes 1.0.1", "core: return 2", "core/a.go"} {
+				if !strings.Contains(pr.Data.Body, want) {
+					t.Errorf("PR body has no %q:\n%s", want, pr.Data.Body)
+				}
+			}
+			for _, unwanted := range []string{"eth: document C", "notes 1.0.2"} {
+				if strings.Contains(pr.Data.Body, unwanted) {
+					t.Errorf("PR body has %q of the next release:\n%s", unwanted, pr.Data.Body)
+				}
+			}
+			if labels := quorum.Labels[pr.Data.Number]; len(labels) != 1 || labels[0] != cfg.GithubLabel {
+				t.Errorf("labels = %v, want %s", labels, cfg.GithubLabel)
+			}
+
+			// the open PR is not opened again
+			if err := commands["upgrade"].run(context.Background(), []string{"--config", configPath}); err != nil {
+				t.Fatalf("second upgrade: %v", err)
+			}
+			if len(quorum.PullRequests) != 1 {
+				t.Errorf("PRs after a second upgrade = %+v, want one PR", quorum.PullRequests)
+			}
+		})
+	}
+}
+
+func TestUpgradeDryRun(t *testing.T) {
+	cfg := config.Default()
+	cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", "ghp_testtoken"
+	f, fx := newUpgradeFixture(t, cfg)
+	server := fake.NewServer(fx)
+	defer server.Close()
+	f.Configure(cfg)
+	server.Configure(cfg)
+	configPath := writeConfig(t, f.Dir, cfg)
+	reportPath := filepath.Join(f.Dir, "report.md")
+
+	if err := commands["upgrade"].run(context.Background(), []string{"--config", configPath, "--dry-run", "--output", reportPath}); err != nil {
+		t.Fatalf("upgrade --dry-run: %v", err)
+	}
+
+	quorum := server.Fixture().Repository(cfg.QuorumRepoName)
+	if len(quorum.PullRequests) != 0 || len(quorum.Labels) != 0 {
+		t.Errorf("PRs = %+v, labels = %v, want none in dry-run", quorum.PullRequests, quorum.Labels)
+	}
+	// with the REST backend, only the writes are not GET requests
+	for _, request := range server.Requests() {
+		if !strings.HasPrefix(request, "GET ") {
+			t.Errorf("request %s in dry-run, want only GET requests", request)
+		}
+	}
+	branches, err := f.BotBranches()
+	if err != nil || len(branches) != 0 {
+		t.Errorf("bot branches = %v, %v, want none in dry-run", branches, err)
+	}
+	report, err := ioutil.ReadFile(reportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{github.UpgradePullRequestTitle("v1.0.1"), "notes 1.0.1", "[#101](https://github.com/ethereum/go-ethereum/pull/101)", "``core: return 2``"} {
+		if !strings.Contains(string(report), want) {
+			t.Errorf("report has no %q:\n%s", want, report)
+		}
+	}
+}
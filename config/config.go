@@ -1,56 +1,454 @@
 package config
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
 	"os"
-	"sync"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
 )
 
-var once sync.Once
+// EnvConfigFile - env var holding the path of the config file, when not given with a flag
+const EnvConfigFile = "UPGRADEBOT_CONFIG"
+
+// GitHub API backends
+const (
+	GithubBackendREST    = "rest"
+	GithubBackendGraphQL = "graphql"
+)
+
+// policies choosing the go-ethereum release to upgrade to
+const (
+	UpgradePolicyNext                   = "next"
+	UpgradePolicyLatestPatchOfNextMinor = "latest-patch-of-next-minor"
+	UpgradePolicyLatest                 = "latest"
+)
+
+// sources of the go-ethereum version merged into Quorum
+const (
+	BaseVersionFromFile    = "version-file"
+	BaseVersionFromHistory = "git-history"
+)
+
+// criticality of the areas of the go-ethereum code for Quorum
+const (
+	CriticalityHigh   = "high"
+	CriticalityMedium = "medium"
+)
+
+// git backends
+const (
+	GitBackendExec  = "exec"
+	GitBackendGoGit = "go-git"
+)
 
 type Config struct {
-	GithubAPIUrl string
-	GithubLabel  string
+	GithubAPIUrl     string `yaml:"githubApiUrl"`
+	GithubGraphQLUrl string `yaml:"githubGraphqlUrl"`
+	GithubLabel      string `yaml:"githubLabel"`
+	// GithubBackend - GitHub API used to get the releases, PRs and files: `rest` or `graphql`
+	GithubBackend string `yaml:"githubBackend"`
+
+	QuorumGitRepo    string `yaml:"quorumGitRepo"`
+	QuorumBotGitRepo string `yaml:"quorumBotGitRepo"`
+	QuorumBotOwner   string `yaml:"quorumBotOwner"`
+	QuorumAPIUrl     string `yaml:"quorumApiUrl"`
+	QuorumRepoName   string `yaml:"quorumRepoName"`
+	QuorumBaseBranch string `yaml:"quorumBaseBranch"`
+
+	GethGitRepo      string `yaml:"gethGitRepo"`
+	GethGithubAPIUrl string `yaml:"gethGithubApiUrl"`
+	GethRepoName     string `yaml:"gethRepoName"`
+	// UpgradePolicy - release to upgrade to when no target is given: `next`, `latest-patch-of-next-minor` or `latest`
+	UpgradePolicy string `yaml:"upgradePolicy"`
+	// IncludePrereleases - whether an upgrade can target a go-ethereum prerelease
+	IncludePrereleases bool `yaml:"includePrereleases"`
 
-	QuorumGitRepo    string
-	QuorumBotGitRepo string
-	QuorumAPIUrl     string
+	GithubUsername  string `yaml:"githubUsername"`
+	GithubUserToken string `yaml:"githubUserToken"`
 
-	GethGitRepo      string
-	GethGithubAPIUrl string
+	QuorumRepoFolder      string `yaml:"quorumRepoFolder"`
+	QuorumVersionFilePath string `yaml:"quorumVersionFilePath"`
+	// BaseVersionSource - source of the geth version merged into Quorum: `version-file` or `git-history`, cross-checked with the other one
+	BaseVersionSource string `yaml:"baseVersionSource"`
+	// QuorumMirrorFolder - persistent mirror of Quorum fetched incrementally, with a worktree per run in QuorumRepoFolder.
+	// Disabled if empty, requires the exec git backend.
+	QuorumMirrorFolder string `yaml:"quorumMirrorFolder"`
+	// GitBackend - implementation of the git operations: `exec` runs the git binary, `go-git` needs no binary but cannot detect the merge conflicts
+	GitBackend string `yaml:"gitBackend"`
 
-	GithubUsername  string
-	GithubUserToken string
+	// HTTPCacheFolder - folder of the on-disk cache of the GitHub GET responses, disabled if empty
+	HTTPCacheFolder string `yaml:"httpCacheFolder"`
+	// GithubWorkers - maximum number of concurrent GitHub requests when fetching the PRs of a release
+	GithubWorkers int `yaml:"githubWorkers"`
 
-	QuorumRepoFolder      string
-	QuorumVersionFilePath string
+	// RiskWeights - weights of the risk score of the PRs and changed files
+	RiskWeights RiskWeights `yaml:"riskWeights"`
+	// CriticalAreas - areas of the go-ethereum code that are risky for Quorum, the first area matching a file applies
+	CriticalAreas []CriticalArea `yaml:"criticalAreas"`
 }
 
-var (
-	instance *Config
-)
+// CriticalArea - files of the go-ethereum code that are risky for Quorum, and their owner in the Quorum team
+type CriticalArea struct {
+	// Path - glob of the files, as for path.Match with `**` matching any number of directories (e.g. core/state/**)
+	Path string `yaml:"path"`
+	// Criticality - `high` makes any change of the files a Warning at least, `medium` only raises their risk score
+	Criticality string `yaml:"criticality"`
+	// Owner - reviewer of the area, optional
+	Owner string `yaml:"owner"`
+}
+
+// RiskWeights - weights of the factors of the risk score of a PR or changed file
+type RiskWeights struct {
+	// LinesChanged - per doubling of the lines added and removed
+	LinesChanged float64 `yaml:"linesChanged"`
+	// QuorumFile - per file changed by Quorum too
+	QuorumFile float64 `yaml:"quorumFile"`
+	// ConflictFile - per file with merge conflicts
+	ConflictFile float64 `yaml:"conflictFile"`
+	// OverlappingHunk - per upstream hunk overlapping Quorum changes
+	OverlappingHunk float64 `yaml:"overlappingHunk"`
+	// AdjacentHunk - per upstream hunk adjacent to Quorum changes
+	AdjacentHunk float64 `yaml:"adjacentHunk"`
+	// ImpactedSymbol - per symbol changed upstream that Quorum modified or uses
+	ImpactedSymbol float64 `yaml:"impactedSymbol"`
+	// HighCriticalityFile - package criticality, per file of a critical area of high criticality
+	HighCriticalityFile float64 `yaml:"highCriticalityFile"`
+	// MediumCriticalityFile - package criticality, per file of a critical area of medium criticality
+	MediumCriticalityFile float64 `yaml:"mediumCriticalityFile"`
+	// TestOnly - factor of the score of the changes of test files only
+	TestOnly float64 `yaml:"testOnly"`
+}
 
-func GetConfig() *Config {
-	once.Do(func() {
-		githubUsername := os.Getenv("GITHUB_USERNAME")
-		githubToken := os.Getenv("GITHUB_USER_TOKEN")
-		instance = &Config{
-			GithubAPIUrl: "https://api.github.com",
-			GithubLabel:  "geth upstream upgrade",
+// Default - config targeting the Consensys/quorum and ethereum/go-ethereum repositories, without credentials
+func Default() *Config {
+	return &Config{
+		GithubAPIUrl:     "https://api.github.com",
+		GithubGraphQLUrl: "https://api.github.com/graphql",
+		GithubLabel:      "geth upstream upgrade",
+		GithubBackend:    GithubBackendREST,
 
-			GithubUsername:  githubUsername,
-			GithubUserToken: githubToken,
+		GethGitRepo:      "https://github.com/ethereum/go-ethereum.git",
+		GethGithubAPIUrl: "https://api.github.com/repos/ethereum/go-ethereum",
+		GethRepoName:     "ethereum/go-ethereum",
+		UpgradePolicy:    UpgradePolicyNext,
 
-			GethGitRepo:      "https://github.com/ethereum/go-ethereum.git",
-			GethGithubAPIUrl: "https://api.github.com/repos/ethereum/go-ethereum",
+		QuorumGitRepo:    "https://github.com/Consensys/quorum.git",
+		QuorumBotGitRepo: "https://github.com/quorumbot/quorum.git",
+		QuorumBotOwner:   "quorumbot",
+		QuorumAPIUrl:     "https://api.github.com/repos/Consensys/quorum",
+		QuorumRepoName:   "Consensys/quorum",
+		QuorumBaseBranch: "master",
 
-			QuorumGitRepo:    "https://" + githubUsername + ":" + githubToken + "@github.com/Consensys/quorum.git",
-			QuorumBotGitRepo: "https://" + githubUsername + ":" + githubToken + "@github.com/quorumbot/quorum.git",
-			QuorumAPIUrl:     "https://api.github.com/repos/Consensys/quorum",
+		QuorumRepoFolder:      "tmp-quorum-repo",
+		QuorumVersionFilePath: "/params/version.go",
+		BaseVersionSource:     BaseVersionFromFile,
+		GitBackend:            GitBackendExec,
 
-			QuorumRepoFolder:      "tmp-quorum-repo",
-			QuorumVersionFilePath: "/params/version.go",
+		GithubWorkers: 4,
+
+		RiskWeights: RiskWeights{
+			LinesChanged:          1,
+			QuorumFile:            5,
+			ConflictFile:          20,
+			OverlappingHunk:       3,
+			AdjacentHunk:          1,
+			ImpactedSymbol:        2,
+			HighCriticalityFile:   10,
+			MediumCriticalityFile: 3,
+			TestOnly:              0.2,
+		},
+		CriticalAreas: []CriticalArea{
+			{Path: "core/state/**", Criticality: CriticalityHigh},
+			{Path: "core/vm/**", Criticality: CriticalityHigh},
+			{Path: "consensus/**", Criticality: CriticalityHigh},
+			{Path: "eth/protocols/**", Criticality: CriticalityHigh},
+			{Path: "miner/**", Criticality: CriticalityHigh},
+			{Path: "p2p/**", Criticality: CriticalityHigh},
+			// blockchain, state processor and transaction pool, modified for private transactions
+			{Path: "core/*", Criticality: CriticalityMedium},
+			{Path: "core/types/**", Criticality: CriticalityMedium},
+			// the JSON-RPC APIs, extended with the private transaction arguments
+			{Path: "internal/ethapi/**", Criticality: CriticalityMedium},
+		},
+	}
+}
+
+// Load - load the config: defaults, overridden by the YAML file at path (if any), overridden by env vars
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv(EnvConfigFile)
+	}
+	if path != "" {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	for name, field := range cfg.envFields() {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+	for name, field := range cfg.envBoolFields() {
+		if value, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("parse env var %s: %w", name, err)
+			}
+			*field = b
 		}
+	}
+	for name, field := range cfg.envIntFields() {
+		if value, ok := os.LookupEnv(name); ok {
+			i, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("parse env var %s: %w", name, err)
+			}
+			*field = i
+		}
+	}
+	for name, field := range cfg.envFloatFields() {
+		if value, ok := os.LookupEnv(name); ok {
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse env var %s: %w", name, err)
+			}
+			*field = f
+		}
+	}
+
+	return cfg, nil
+}
+
+// envFields - env var overriding each field of the config
+func (c *Config) envFields() map[string]*string {
+	return map[string]*string{
+		"UPGRADEBOT_GITHUB_API_URL":     &c.GithubAPIUrl,
+		"UPGRADEBOT_GITHUB_GRAPHQL_URL": &c.GithubGraphQLUrl,
+		"UPGRADEBOT_GITHUB_LABEL":       &c.GithubLabel,
+		"UPGRADEBOT_GITHUB_BACKEND":     &c.GithubBackend,
+
+		"UPGRADEBOT_QUORUM_GIT_REPO":     &c.QuorumGitRepo,
+		"UPGRADEBOT_QUORUM_BOT_GIT_REPO": &c.QuorumBotGitRepo,
+		"UPGRADEBOT_QUORUM_BOT_OWNER":    &c.QuorumBotOwner,
+		"UPGRADEBOT_QUORUM_API_URL":      &c.QuorumAPIUrl,
+		"UPGRADEBOT_QUORUM_REPO_NAME":    &c.QuorumRepoName,
+		"UPGRADEBOT_QUORUM_BASE_BRANCH":  &c.QuorumBaseBranch,
+
+		"UPGRADEBOT_GETH_GIT_REPO":       &c.GethGitRepo,
+		"UPGRADEBOT_GETH_GITHUB_API_URL": &c.GethGithubAPIUrl,
+		"UPGRADEBOT_GETH_REPO_NAME":      &c.GethRepoName,
+		"UPGRADEBOT_UPGRADE_POLICY":      &c.UpgradePolicy,
+
+		"GITHUB_USERNAME":   &c.GithubUsername,
+		"GITHUB_USER_TOKEN": &c.GithubUserToken,
 
-	})
-	return instance
+		"UPGRADEBOT_QUORUM_REPO_FOLDER":       &c.QuorumRepoFolder,
+		"UPGRADEBOT_QUORUM_VERSION_FILE_PATH": &c.QuorumVersionFilePath,
+		"UPGRADEBOT_BASE_VERSION_SOURCE":      &c.BaseVersionSource,
+		"UPGRADEBOT_QUORUM_MIRROR_FOLDER":     &c.QuorumMirrorFolder,
+		"UPGRADEBOT_GIT_BACKEND":              &c.GitBackend,
+
+		"UPGRADEBOT_HTTP_CACHE_FOLDER": &c.HTTPCacheFolder,
+	}
+}
+
+// envIntFields - env var overriding each integer field of the config
+func (c *Config) envIntFields() map[string]*int {
+	return map[string]*int{
+		"UPGRADEBOT_GITHUB_WORKERS": &c.GithubWorkers,
+	}
+}
+
+// envFloatFields - env var overriding each float field of the config
+func (c *Config) envFloatFields() map[string]*float64 {
+	return map[string]*float64{
+		"UPGRADEBOT_RISK_WEIGHT_LINES_CHANGED":           &c.RiskWeights.LinesChanged,
+		"UPGRADEBOT_RISK_WEIGHT_QUORUM_FILE":             &c.RiskWeights.QuorumFile,
+		"UPGRADEBOT_RISK_WEIGHT_CONFLICT_FILE":           &c.RiskWeights.ConflictFile,
+		"UPGRADEBOT_RISK_WEIGHT_OVERLAPPING_HUNK":        &c.RiskWeights.OverlappingHunk,
+		"UPGRADEBOT_RISK_WEIGHT_ADJACENT_HUNK":           &c.RiskWeights.AdjacentHunk,
+		"UPGRADEBOT_RISK_WEIGHT_IMPACTED_SYMBOL":         &c.RiskWeights.ImpactedSymbol,
+		"UPGRADEBOT_RISK_WEIGHT_HIGH_CRITICALITY_FILE":   &c.RiskWeights.HighCriticalityFile,
+		"UPGRADEBOT_RISK_WEIGHT_MEDIUM_CRITICALITY_FILE": &c.RiskWeights.MediumCriticalityFile,
+		"UPGRADEBOT_RISK_WEIGHT_TEST_ONLY":               &c.RiskWeights.TestOnly,
+	}
+}
+
+// envBoolFields - env var overriding each boolean field of the config
+func (c *Config) envBoolFields() map[string]*bool {
+	return map[string]*bool{
+		"UPGRADEBOT_INCLUDE_PRERELEASES": &c.IncludePrereleases,
+	}
+}
+
+// Validate - check that the credentials are set and that the URLs are well-formed, reporting every invalid field
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.GithubUsername == "" {
+		problems = append(problems, "missing github username (GITHUB_USERNAME)")
+	}
+	if c.GithubUserToken == "" {
+		problems = append(problems, "missing github user token (GITHUB_USER_TOKEN)")
+	}
+
+	apiUrls := map[string]string{
+		"githubApiUrl":     c.GithubAPIUrl,
+		"githubGraphqlUrl": c.GithubGraphQLUrl,
+		"quorumApiUrl":     c.QuorumAPIUrl,
+		"gethGithubApiUrl": c.GethGithubAPIUrl,
+	}
+	for name, value := range apiUrls {
+		if err := validateHTTPUrl(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	gitRepos := map[string]string{
+		"quorumGitRepo":    c.QuorumGitRepo,
+		"quorumBotGitRepo": c.QuorumBotGitRepo,
+		"gethGitRepo":      c.GethGitRepo,
+	}
+	for name, value := range gitRepos {
+		if err := validateGitRepo(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	required := map[string]string{
+		"quorumBotOwner":        c.QuorumBotOwner,
+		"quorumBaseBranch":      c.QuorumBaseBranch,
+		"quorumRepoFolder":      c.QuorumRepoFolder,
+		"quorumVersionFilePath": c.QuorumVersionFilePath,
+	}
+	for name, value := range required {
+		if value == "" {
+			problems = append(problems, name+": missing value")
+		}
+	}
+
+	repoNames := map[string]string{
+		"gethRepoName":   c.GethRepoName,
+		"quorumRepoName": c.QuorumRepoName,
+	}
+	for name, value := range repoNames {
+		if parts := strings.Split(value, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			problems = append(problems, fmt.Sprintf("%s: malformed repository name %q, expected owner/name", name, value))
+		}
+	}
+
+	if c.GithubBackend != GithubBackendREST && c.GithubBackend != GithubBackendGraphQL {
+		problems = append(problems, fmt.Sprintf("githubBackend: unknown backend %q, expected %s or %s", c.GithubBackend, GithubBackendREST, GithubBackendGraphQL))
+	}
+	switch c.UpgradePolicy {
+	case UpgradePolicyNext, UpgradePolicyLatestPatchOfNextMinor, UpgradePolicyLatest:
+	default:
+		problems = append(problems, fmt.Sprintf("upgradePolicy: unknown policy %q, expected %s, %s or %s", c.UpgradePolicy, UpgradePolicyNext, UpgradePolicyLatestPatchOfNextMinor, UpgradePolicyLatest))
+	}
+	if c.BaseVersionSource != BaseVersionFromFile && c.BaseVersionSource != BaseVersionFromHistory {
+		problems = append(problems, fmt.Sprintf("baseVersionSource: unknown source %q, expected %s or %s", c.BaseVersionSource, BaseVersionFromFile, BaseVersionFromHistory))
+	}
+	if c.GitBackend != GitBackendExec && c.GitBackend != GitBackendGoGit {
+		problems = append(problems, fmt.Sprintf("gitBackend: unknown backend %q, expected %s or %s", c.GitBackend, GitBackendExec, GitBackendGoGit))
+	}
+	if c.QuorumMirrorFolder != "" && c.GitBackend != GitBackendExec {
+		problems = append(problems, fmt.Sprintf("quorumMirrorFolder: requires the %s git backend", GitBackendExec))
+	}
+	if c.GithubWorkers < 1 {
+		problems = append(problems, "githubWorkers: must be at least 1")
+	}
+	riskWeights := map[string]float64{
+		"riskWeights.linesChanged":          c.RiskWeights.LinesChanged,
+		"riskWeights.quorumFile":            c.RiskWeights.QuorumFile,
+		"riskWeights.conflictFile":          c.RiskWeights.ConflictFile,
+		"riskWeights.overlappingHunk":       c.RiskWeights.OverlappingHunk,
+		"riskWeights.adjacentHunk":          c.RiskWeights.AdjacentHunk,
+		"riskWeights.impactedSymbol":        c.RiskWeights.ImpactedSymbol,
+		"riskWeights.highCriticalityFile":   c.RiskWeights.HighCriticalityFile,
+		"riskWeights.mediumCriticalityFile": c.RiskWeights.MediumCriticalityFile,
+		"riskWeights.testOnly":              c.RiskWeights.TestOnly,
+	}
+	for name, value := range riskWeights {
+		if value < 0 {
+			problems = append(problems, name+": must not be negative")
+		}
+	}
+	for i, area := range c.CriticalAreas {
+		if err := validatePathGlob(area.Path); err != nil {
+			problems = append(problems, fmt.Sprintf("criticalAreas[%d].path: %v", i, err))
+		}
+		if area.Criticality != CriticalityHigh && area.Criticality != CriticalityMedium {
+			problems = append(problems, fmt.Sprintf("criticalAreas[%d].criticality: unknown criticality %q, expected %s or %s", i, area.Criticality, CriticalityHigh, CriticalityMedium))
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+}
+
+func validateHTTPUrl(value string) error {
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("malformed URL %q, expected http(s)://host/...", value)
+	}
+	return nil
+}
+
+// validatePathGlob - accept the globs of path.Match, with `**` segments
+func validatePathGlob(value string) error {
+	if value == "" {
+		return fmt.Errorf("missing value")
+	}
+	for _, segment := range strings.Split(value, "/") {
+		if _, err := path.Match(segment, ""); err != nil {
+			return fmt.Errorf("malformed glob %q: %w", value, err)
+		}
+	}
+	return nil
+}
+
+// validateGitRepo - accept remote URLs and absolute paths of local repositories
+func validateGitRepo(value string) error {
+	if filepath.IsAbs(value) {
+		return nil
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https":
+		if u.User != nil {
+			return fmt.Errorf("git repository URL %q must not contain credentials", value)
+		}
+		fallthrough
+	case "ssh", "git":
+		if u.Host == "" {
+			return fmt.Errorf("malformed git repository URL %q, missing host", value)
+		}
+	case "file":
+	default:
+		return fmt.Errorf("malformed git repository URL %q", value)
+	}
+	return nil
 }
@@ -1,3 +1,10 @@
 module upgradebot
 
 go 1.15
+
+require (
+	github.com/go-git/go-billy/v5 v5.3.1
+	github.com/go-git/go-git/v5 v5.4.2
+	golang.org/x/mod v0.4.2
+	gopkg.in/yaml.v2 v2.4.0
+)
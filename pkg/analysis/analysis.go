@@ -5,56 +5,174 @@ import (
 	"sort"
 	"strings"
 
+	"upgradebot/config"
 	"upgradebot/pkg/github"
 )
 
 // GetAnalysis - create analysis that will provide
-// * all PRs merged in the new version (including risk assessment, files changed, packages changed, etc)
-// * the list of all files changed (including risk assessment and linked PR where the file was changed)
-func GetAnalysis(tagCompare github.TagCompare, filesChangedByQuorum []string, expectedFileConflicts []string) Analysis {
+// * all PRs merged in the new version (including risk assessment, files changed, packages changed, etc), from the riskiest
+// * the list of all files changed (including risk assessment and linked PR where the file was changed), from the riskiest
+//
+// Without hunks, every file changed by Quorum is a Warning. The symbol impacts are optional too.
+// A PR with hunks is assessed with its own hunks, so that a PR changing other regions of a file than Quorum is not
+// a Warning nor a Conflict because of the other PRs changing the file.
+// Any change of a critical area of high criticality is a Warning at least.
+func GetAnalysis(tagCompare github.TagCompare, filesChangedByQuorum []string, expectedFileConflicts []string, hunks UpgradeHunks, symbolImpacts map[string][]SymbolImpact, riskModel RiskModel, criticalAreas []config.CriticalArea) Analysis {
 	analysis := Analysis{}
 	analysis.PrStats = make([]PullRequestStats, len(tagCompare.PullRequests))
 
 	// pre-processing
+	hunkOverlaps := GetHunkOverlaps(hunks.Quorum, hunks.Upstream)
 	mapFileAssessment := make(map[string]Assessment)
 	for _, file := range filesChangedByQuorum {
 		mapFileAssessment[file] = Warning
+		if overlap, ok := hunkOverlaps[file]; ok && overlap.IsDisjoint() {
+			mapFileAssessment[file] = SameFile
+		}
 	}
 	for _, file := range expectedFileConflicts {
 		mapFileAssessment[file] = Conflict
 	}
+	// the files with conflicts are changed by Quorum too
+	changedByQuorum := make(map[string]bool)
+	for file, assessment := range mapFileAssessment {
+		changedByQuorum[file] = assessment.level() > Good.level()
+	}
+
+	mapFileArea := make(map[string]config.CriticalArea)
+	addCriticalArea := func(filename string) {
+		area, ok := getCriticalArea(criticalAreas, filename)
+		if !ok {
+			return
+		}
+		mapFileArea[filename] = area
+		if area.Criticality == config.CriticalityHigh && mapFileAssessment[filename].level() < Warning.level() {
+			mapFileAssessment[filename] = Warning
+		}
+	}
+	for _, file := range tagCompare.Files {
+		addCriticalArea(file.Filename)
+	}
+	for _, pr := range tagCompare.PullRequests {
+		for _, file := range pr.Files {
+			addCriticalArea(file.Filename)
+		}
+	}
 
-	// processing & ordering PRs
+	// processing & ordering PRs, the riskiest first and in merge order for the same risk
 	for i, pr := range tagCompare.PullRequests {
-		analysis.PrStats[i] = getPullRequestStats(pr, mapFileAssessment)
+		prAssessments, prOverlaps := getPullRequestAssessments(pr, hunks, mapFileAssessment, hunkOverlaps, mapFileArea)
+		prSymbols := getPullRequestSymbols(pr, hunks, symbolImpacts)
+		analysis.PrStats[i] = getPullRequestStats(pr, prAssessments, prOverlaps, prSymbols)
+
+		factors := RiskFactors{TestOnly: true}
+		prAreas := make(map[string]bool)
+		for _, file := range pr.Files {
+			factors = factors.add(getFileRiskFactors(file, prAssessments[file.Filename], changedByQuorum[file.Filename],
+				prOverlaps[file.Filename], prSymbols[file.Filename], mapFileArea[file.Filename]))
+			if area, ok := mapFileArea[file.Filename]; ok {
+				prAreas[area.Path] = true
+			}
+		}
+		analysis.PrStats[i].Risk = riskModel.Score(factors)
+		for _, area := range criticalAreas {
+			if prAreas[area.Path] {
+				analysis.PrStats[i].CriticalAreas = append(analysis.PrStats[i].CriticalAreas, area)
+			}
+		}
 	}
 
 	sort.SliceStable(analysis.PrStats, func(i, j int) bool {
 		return analysis.PrStats[i].Data.ClosedAt < analysis.PrStats[j].Data.ClosedAt
 	})
+	sort.SliceStable(analysis.PrStats, func(i, j int) bool {
+		return analysis.PrStats[i].Risk > analysis.PrStats[j].Risk
+	})
 
-	analysis.FileStats = getChangedFilesStats(tagCompare, mapFileAssessment)
+	analysis.FileStats = getChangedFilesStats(tagCompare, mapFileAssessment, hunkOverlaps)
+	for i := range analysis.FileStats {
+		file := analysis.FileStats[i].File
+		analysis.FileStats[i].Risk = riskModel.Score(getFileRiskFactors(file, mapFileAssessment[file.Filename], changedByQuorum[file.Filename],
+			hunkOverlaps[file.Filename], symbolImpacts[file.Filename], mapFileArea[file.Filename]))
+		analysis.FileStats[i].ChangedByQuorum = changedByQuorum[analysis.FileStats[i].File.Filename]
+	}
+	sort.SliceStable(analysis.FileStats, func(i, j int) bool {
+		return analysis.FileStats[i].Risk > analysis.FileStats[j].Risk
+	})
 
 	return analysis
 }
 
-func getPullRequestStats(pr github.PullRequest, mapFileAssessment map[string]Assessment) PullRequestStats {
+// getPullRequestAssessments - assessment and hunk overlap of each file of a PR, from the hunks of the PR if any,
+// otherwise from the hunks of the whole upgrade, shared by the PRs changing the same file
+func getPullRequestAssessments(pr github.PullRequest, hunks UpgradeHunks, mapFileAssessment map[string]Assessment, hunkOverlaps map[string]HunkOverlap, mapFileArea map[string]config.CriticalArea) (map[string]Assessment, map[string]HunkOverlap) {
+	assessments := make(map[string]Assessment)
+	overlaps := make(map[string]HunkOverlap)
+	// the files changed by Quorum and the PR, both with hunks
+	prOverlaps := GetHunkOverlaps(hunks.Quorum, hunks.PullRequests[pr.Data.Number])
+	for _, file := range pr.Files {
+		assessments[file.Filename] = mapFileAssessment[file.Filename]
+		overlaps[file.Filename] = hunkOverlaps[file.Filename]
+		overlap, ok := prOverlaps[file.Filename]
+		if !ok {
+			continue
+		}
+		overlaps[file.Filename] = overlap
+		// the hunks may lower a Warning, but a conflict reported by git stays a Conflict
+		switch {
+		case assessments[file.Filename] == Conflict:
+		case !overlap.IsDisjoint():
+			assessments[file.Filename] = Warning
+		case mapFileArea[file.Filename].Criticality == config.CriticalityHigh:
+			assessments[file.Filename] = Warning
+		default:
+			assessments[file.Filename] = SameFile
+		}
+	}
+	return assessments, overlaps
+}
+
+// getPullRequestSymbols - impacted symbols of each file of a PR: the ones whose declaration intersects the hunks of the PR if any,
+// otherwise all the impacted symbols of the file
+func getPullRequestSymbols(pr github.PullRequest, hunks UpgradeHunks, symbolImpacts map[string][]SymbolImpact) map[string][]SymbolImpact {
+	symbols := make(map[string][]SymbolImpact)
+	prHunks := hunks.PullRequests[pr.Data.Number]
+	for _, file := range pr.Files {
+		fileHunks, ok := prHunks[file.Filename]
+		if !ok {
+			symbols[file.Filename] = symbolImpacts[file.Filename]
+			continue
+		}
+		for _, impact := range symbolImpacts[file.Filename] {
+			for _, hunk := range fileHunks {
+				if hunkDistance(hunk, impact.BaseLines) == 0 {
+					symbols[file.Filename] = append(symbols[file.Filename], impact)
+					break
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+func getPullRequestStats(pr github.PullRequest, mapFileAssessment map[string]Assessment, hunkOverlaps map[string]HunkOverlap, symbolImpacts map[string][]SymbolImpact) PullRequestStats {
 	stats := PullRequestStats{}
 
 	stats.Data = pr.Data
 
 	mapPackageChanged := make(map[string]int)
 
+	// the most severe assessment of its files
+	stats.Assessment = Good
 	for _, file := range pr.Files {
-		stats.Assessment = Good
-		if val, ok := mapFileAssessment[file.Filename]; stats.Assessment == Good && ok {
-			if stats.Assessment != Conflict {
-				stats.Assessment = val
-			}
+		if val, ok := mapFileAssessment[file.Filename]; ok && val.level() > stats.Assessment.level() {
+			stats.Assessment = val
 		}
 
 		stats.LinesAddedCount += file.Additions
 		stats.LinesRemovedCount += file.Deletions
+		stats.Overlap = stats.Overlap.add(hunkOverlaps[file.Filename])
+		stats.Symbols = append(stats.Symbols, symbolImpacts[file.Filename]...)
 
 		lastIndex := strings.LastIndex(file.Filename, "/")
 		packagePath := file.Filename
@@ -97,7 +215,7 @@ func getPullRequestStats(pr github.PullRequest, mapFileAssessment map[string]Ass
 	return stats
 }
 
-func getChangedFilesStats(tagCompare github.TagCompare, mapFileAssessment map[string]Assessment) []ChangedFileStats {
+func getChangedFilesStats(tagCompare github.TagCompare, mapFileAssessment map[string]Assessment, hunkOverlaps map[string]HunkOverlap) []ChangedFileStats {
 	prsPerFile := make(map[string][]github.PullRequestData)
 	filePerFile := make(map[string]github.File)
 
@@ -109,6 +227,10 @@ func getChangedFilesStats(tagCompare github.TagCompare, mapFileAssessment map[st
 	for _, pr := range tagCompare.PullRequests {
 		for _, file := range pr.Files {
 			prsPerFile[file.Filename] = append(prsPerFile[file.Filename], pr.Data)
+			// a file missing from the comparison (e.g. truncated) has the stats of its PR
+			if _, ok := filePerFile[file.Filename]; !ok {
+				filePerFile[file.Filename] = file
+			}
 		}
 	}
 
@@ -116,7 +238,7 @@ func getChangedFilesStats(tagCompare github.TagCompare, mapFileAssessment map[st
 
 	i := 0
 	for name, v := range prsPerFile {
-		stats[i] = ChangedFileStats{AssociatedPRs: v, File: filePerFile[name], Assessment: mapFileAssessment[name]}
+		stats[i] = ChangedFileStats{AssociatedPRs: v, File: filePerFile[name], Assessment: mapFileAssessment[name], Overlap: hunkOverlaps[name]}
 		i++
 	}
 
@@ -126,3 +248,27 @@ func getChangedFilesStats(tagCompare github.TagCompare, mapFileAssessment map[st
 
 	return stats
 }
+
+// getFileRiskFactors - risk factors of a file changed upstream, the critical area being empty for a file of none
+func getFileRiskFactors(file github.File, assessment Assessment, changedByQuorum bool, overlap HunkOverlap, impacts []SymbolImpact, area config.CriticalArea) RiskFactors {
+	factors := RiskFactors{
+		LinesChanged:     file.Additions + file.Deletions,
+		OverlappingHunks: overlap.Overlapping,
+		AdjacentHunks:    overlap.Adjacent,
+		ImpactedSymbols:  len(impacts),
+		TestOnly:         isTestFile(file.Filename),
+	}
+	if changedByQuorum {
+		factors.QuorumFiles = 1
+	}
+	if assessment == Conflict {
+		factors.ConflictFiles = 1
+	}
+	switch area.Criticality {
+	case config.CriticalityHigh:
+		factors.HighCriticalityFiles = 1
+	case config.CriticalityMedium:
+		factors.MediumCriticalityFiles = 1
+	}
+	return factors
+}
@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"testing"
+
+	"upgradebot/config"
+	"upgradebot/pkg/git"
+	"upgradebot/pkg/github"
+)
+
+func TestGetAnalysisAssessments(t *testing.T) {
+	file := func(name string) github.File {
+		return github.File{Status: "modified", Filename: name, Additions: 1, Deletions: 1, Changes: 2}
+	}
+	pr := func(number int, files ...github.File) github.PullRequest {
+		return github.PullRequest{Data: github.PullRequestData{Number: number}, Files: files}
+	}
+	tagCompare := github.TagCompare{
+		Files: []github.File{file("core/conflict.go"), file("core/warning.go")},
+		PullRequests: []github.PullRequest{
+			pr(1, file("core/conflict.go"), file("core/warning.go")),
+			pr(2, file("core/conflict.go"), file("core/warning.go")),
+		},
+	}
+	hunks := UpgradeHunks{
+		Quorum: map[string][]git.Hunk{
+			"core/conflict.go": {{Start: 10, Count: 1}},
+			"core/warning.go":  {{Start: 10, Count: 1}},
+		},
+		Upstream: map[string][]git.Hunk{
+			"core/conflict.go": {{Start: 10, Count: 1}, {Start: 50, Count: 1}},
+			"core/warning.go":  {{Start: 10, Count: 1}, {Start: 50, Count: 1}},
+		},
+		PullRequests: map[int]map[string][]git.Hunk{
+			// overlapping Quorum changes
+			1: {"core/conflict.go": {{Start: 10, Count: 1}}, "core/warning.go": {{Start: 10, Count: 1}}},
+			// disjoint from Quorum changes
+			2: {"core/conflict.go": {{Start: 50, Count: 1}}, "core/warning.go": {{Start: 50, Count: 1}}},
+		},
+	}
+
+	analysis := GetAnalysis(tagCompare, []string{"core/conflict.go", "core/warning.go"}, []string{"core/conflict.go"}, hunks,
+		nil, NewRiskModel(config.Default().RiskWeights), nil)
+
+	want := map[int]Assessment{1: Conflict, 2: Conflict}
+	for _, stats := range analysis.PrStats {
+		if stats.Assessment != want[stats.Data.Number] {
+			t.Errorf("assessment of PR #%d = %s, want %s", stats.Data.Number, stats.Assessment, want[stats.Data.Number])
+		}
+	}
+	wantFiles := map[string]Assessment{"core/conflict.go": Conflict, "core/warning.go": Warning}
+	for _, stats := range analysis.FileStats {
+		if stats.Assessment != wantFiles[stats.File.Filename] {
+			t.Errorf("assessment of %s = %s, want %s", stats.File.Filename, stats.Assessment, wantFiles[stats.File.Filename])
+		}
+	}
+}
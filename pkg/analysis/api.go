@@ -0,0 +1,285 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// APIBreakage - incompatible change of an exported declaration of a go-ethereum package, used by files added by Quorum
+type APIBreakage struct {
+	// Name - package directory and name of the declaration, with the type for a method or field (e.g. core/types.Header.Number)
+	Name string
+	// Base - declaration at the base tag, empty for a method added to an interface
+	Base string
+	// Target - declaration at the target tag, empty for a removed declaration
+	Target string
+	// Usages - lines of the files added by Quorum using the declaration
+	Usages []APIUsage
+}
+
+type APIUsage struct {
+	File string
+	Line int
+}
+
+// apiPackage - exported declarations of a package, by name (`Type.Member` for methods and fields),
+// and the interfaces that cannot be implemented outside of the package
+type apiPackage struct {
+	declarations     map[string]string
+	sealedInterfaces map[string]bool
+}
+
+// GetAPIBreakages - incompatible changes of the exported API of the go-ethereum packages between the base and the target tag
+// that files added by Quorum use, sorted by name.
+// As for apidiff, removing a declaration, changing its type or adding a method to an interface is incompatible.
+// The declarations are compared as written, without type checking, and only in the files changed upstream:
+// the others have the same declarations at both tags. A file that cannot be parsed is skipped with a warning.
+func GetAPIBreakages(sources SymbolSources) []APIBreakage {
+	baseFiles := parseGoFiles(sources.Base)
+	targetFiles := parseGoFiles(sources.Target)
+	// a file that cannot be parsed at one of the tags is left out at both, rather than reported as removed or added
+	for file := range sources.Base {
+		if _, ok := baseFiles[file]; !ok {
+			delete(targetFiles, file)
+		}
+	}
+	for file := range sources.Target {
+		if _, ok := targetFiles[file]; !ok {
+			delete(baseFiles, file)
+		}
+	}
+	packageNames := make(map[string]string)
+	base := parseAPI(baseFiles, packageNames)
+	target := parseAPI(targetFiles, packageNames)
+	references := parseQuorumReferences(sources.QuorumOnly, packageNames)
+
+	breakages := make([]APIBreakage, 0)
+	for dir, basePackage := range base {
+		targetPackage := target[dir]
+		for name, baseDeclaration := range basePackage.declarations {
+			targetDeclaration := targetPackage.declarations[name]
+			if targetDeclaration == baseDeclaration {
+				continue
+			}
+			// the members of a removed type are reported with the type, which may also be declared in a file unchanged upstream
+			if dot := strings.Index(name, "."); dot >= 0 {
+				_, typeInBase := basePackage.declarations[name[:dot]]
+				_, typeInTarget := targetPackage.declarations[name[:dot]]
+				if typeInBase && !typeInTarget {
+					continue
+				}
+			}
+			breakages = appendBreakage(breakages, references.uses(dir, name), dir, name, baseDeclaration, targetDeclaration)
+		}
+	}
+	for dir, targetPackage := range target {
+		basePackage := base[dir]
+		for name, targetDeclaration := range targetPackage.declarations {
+			dot := strings.Index(name, ".")
+			if _, ok := basePackage.declarations[name]; ok || dot < 0 {
+				continue
+			}
+			// a method added to an interface of the base breaks its implementations, which use the interface rather than the method
+			typeName := name[:dot]
+			if strings.HasSuffix(basePackage.declarations[typeName], " interface") && !targetPackage.sealedInterfaces[typeName] &&
+				strings.HasPrefix(targetDeclaration, "interface ") {
+				breakages = appendBreakage(breakages, references.uses(dir, typeName), dir, name, "", targetDeclaration)
+			}
+		}
+	}
+
+	sort.Slice(breakages, func(i, j int) bool {
+		return breakages[i].Name < breakages[j].Name
+	})
+	return breakages
+}
+
+// appendBreakage - append the breakage of a declaration if files added by Quorum use it
+func appendBreakage(breakages []APIBreakage, uses []reference, dir string, name string, base string, target string) []APIBreakage {
+	if len(uses) == 0 {
+		return breakages
+	}
+	breakage := APIBreakage{Name: symbolName(dir, name), Base: base, Target: target}
+	for _, use := range uses {
+		breakage.Usages = append(breakage.Usages, APIUsage{File: use.file, Line: use.line})
+	}
+	return append(breakages, breakage)
+}
+
+// parseGoFiles - syntax trees of Go files by path, the files that cannot be parsed are skipped with a warning
+func parseGoFiles(sources GoSources) map[string]*ast.File {
+	astFiles := make(map[string]*ast.File)
+	for file, content := range sources {
+		astFile, err := parser.ParseFile(token.NewFileSet(), file, content, 0)
+		if err != nil {
+			log.Printf("Skipping %s in the API analysis: %v\n", file, err)
+			continue
+		}
+		astFiles[file] = astFile
+	}
+	return astFiles
+}
+
+// parseAPI - exported declarations of the packages of the files, by directory.
+// The files are read in order, so that declarations repeated with different build constraints are deterministic.
+func parseAPI(astFiles map[string]*ast.File, packageNames map[string]string) map[string]apiPackage {
+	files := make([]string, 0, len(astFiles))
+	for file := range astFiles {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	packages := make(map[string]apiPackage)
+	for _, file := range files {
+		astFile := astFiles[file]
+		dir := path.Dir(file)
+		packageNames[dir] = astFile.Name.Name
+		apiPackage, ok := packages[dir]
+		if !ok {
+			apiPackage.declarations = make(map[string]string)
+			apiPackage.sealedInterfaces = make(map[string]bool)
+			packages[dir] = apiPackage
+		}
+		for _, decl := range astFile.Decls {
+			apiPackage.addDeclaration(decl)
+		}
+	}
+	return packages
+}
+
+func (p apiPackage) addDeclaration(decl ast.Decl) {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if !decl.Name.IsExported() {
+			return
+		}
+		if decl.Recv == nil || len(decl.Recv.List) == 0 {
+			p.declarations[decl.Name.Name] = "func " + decl.Name.Name + signature(decl.Type)
+			return
+		}
+		receiver := decl.Recv.List[0].Type
+		typeName := receiverTypeName(receiver)
+		if !ast.IsExported(typeName) {
+			return
+		}
+		pointer := ""
+		if _, ok := receiver.(*ast.StarExpr); ok {
+			pointer = "*"
+		}
+		p.declarations[typeName+"."+decl.Name.Name] = fmt.Sprintf("func (%s%s) %s%s", pointer, typeName, decl.Name.Name, signature(decl.Type))
+	case *ast.GenDecl:
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				if spec.Name.IsExported() {
+					p.addType(spec)
+				}
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					if !name.IsExported() {
+						continue
+					}
+					declaration := decl.Tok.String() + " " + name.Name
+					if spec.Type != nil {
+						declaration += " " + types.ExprString(spec.Type)
+					}
+					p.declarations[name.Name] = declaration
+				}
+			}
+		}
+	}
+}
+
+func (p apiPackage) addType(spec *ast.TypeSpec) {
+	name := spec.Name.Name
+	if spec.Assign.IsValid() {
+		p.declarations[name] = "type " + name + " = " + types.ExprString(spec.Type)
+		return
+	}
+	switch typeExpr := spec.Type.(type) {
+	case *ast.StructType:
+		p.declarations[name] = "type " + name + " struct"
+		for _, field := range typeExpr.Fields.List {
+			fieldType := types.ExprString(field.Type)
+			if len(field.Names) == 0 {
+				if embedded := embeddedTypeName(field.Type); ast.IsExported(embedded) {
+					p.declarations[name+"."+embedded] = "field " + fieldType
+				}
+			}
+			for _, fieldName := range field.Names {
+				if fieldName.IsExported() {
+					p.declarations[name+"."+fieldName.Name] = "field " + fieldName.Name + " " + fieldType
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		p.declarations[name] = "type " + name + " interface"
+		for _, method := range typeExpr.Methods.List {
+			funcType, ok := method.Type.(*ast.FuncType)
+			if !ok {
+				p.declarations[name+"."+types.ExprString(method.Type)] = "interface embedded " + types.ExprString(method.Type)
+				continue
+			}
+			for _, methodName := range method.Names {
+				if !methodName.IsExported() {
+					p.sealedInterfaces[name] = true
+					continue
+				}
+				p.declarations[name+"."+methodName.Name] = "interface method " + methodName.Name + signature(funcType)
+			}
+		}
+	default:
+		p.declarations[name] = "type " + name + " " + types.ExprString(spec.Type)
+	}
+}
+
+// signature - parameter and result types of a function, without their names
+func signature(funcType *ast.FuncType) string {
+	params := fieldTypes(funcType.Params)
+	results := fieldTypes(funcType.Results)
+	switch len(results) {
+	case 0:
+		return "(" + strings.Join(params, ", ") + ")"
+	case 1:
+		return "(" + strings.Join(params, ", ") + ") " + results[0]
+	default:
+		return "(" + strings.Join(params, ", ") + ") (" + strings.Join(results, ", ") + ")"
+	}
+}
+
+// fieldTypes - type of each field of a list, repeated for the fields declared together
+func fieldTypes(fields *ast.FieldList) []string {
+	var fieldTypes []string
+	if fields == nil {
+		return fieldTypes
+	}
+	for _, field := range fields.List {
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			fieldTypes = append(fieldTypes, types.ExprString(field.Type))
+		}
+	}
+	return fieldTypes
+}
+
+// embeddedTypeName - name of the field of an embedded type, e.g. Header for *types.Header
+func embeddedTypeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedTypeName(expr.X)
+	case *ast.SelectorExpr:
+		return expr.Sel.Name
+	default:
+		return receiverTypeName(expr)
+	}
+}
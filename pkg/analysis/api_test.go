@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+const apiBase = `package types
+
+type Header struct {
+	Number uint64
+	Extra  []byte
+}
+
+type Signer interface {
+	Sender(tx int) (string, error)
+}
+
+func (h *Header) Hash() string { return "" }
+
+func NewHeader(n uint64) *Header { return nil }
+
+func Unused() {}
+
+const Version = 1
+`
+
+// apiTarget - Number and Version are incompatible changes, Signer has a new method, the other changes are compatible
+const apiTarget = `package types
+
+import "math/big"
+
+type Header struct {
+	Number *big.Int
+	Extra  []byte
+}
+
+type Signer interface {
+	Sender(tx int) (string, error)
+	ChainID() int
+}
+
+func (h *Header) Hash() string { return "hash" }
+
+func NewHeader(number uint64) *Header { return &Header{} }
+
+func Added() {}
+`
+
+const apiPrivate = `package private
+
+import "github.com/ethereum/go-ethereum/core/types"
+
+func Use(h *types.Header, s types.Signer) {
+	_ = types.Version
+	_ = types.NewHeader(1)
+	_ = h.Number
+	_ = h.Hash()
+	types.Gone()
+}
+`
+
+func TestGetAPIBreakages(t *testing.T) {
+	sources := SymbolSources{
+		Base: GoSources{
+			"core/types/header.go": []byte(apiBase),
+			"core/types/gone.go":   []byte("package types\n\nfunc Gone() {}\n"),
+		},
+		Target: GoSources{
+			"core/types/header.go": []byte(apiTarget),
+			// cannot be parsed at the target, Gone must not be reported as removed
+			"core/types/gone.go": []byte("package types\n\nfunc Gone( {}\n"),
+		},
+		QuorumOnly: GoSources{"private/private.go": []byte(apiPrivate)},
+	}
+	want := []APIBreakage{
+		{
+			Name:   "core/types.Header.Number",
+			Base:   "field Number uint64",
+			Target: "field Number *big.Int",
+			Usages: []APIUsage{{File: "private/private.go", Line: 8}},
+		},
+		{
+			Name:   "core/types.Signer.ChainID",
+			Target: "interface method ChainID() int",
+			Usages: []APIUsage{{File: "private/private.go", Line: 5}},
+		},
+		{
+			Name:   "core/types.Version",
+			Base:   "const Version",
+			Usages: []APIUsage{{File: "private/private.go", Line: 6}},
+		},
+	}
+	if breakages := GetAPIBreakages(sources); !reflect.DeepEqual(breakages, want) {
+		t.Errorf("GetAPIBreakages = %+v, want %+v", breakages, want)
+	}
+}
+
+func TestGetAPIBreakagesOfSealedInterface(t *testing.T) {
+	sources := SymbolSources{
+		Base:   GoSources{"core/vm/interface.go": []byte("package vm\n\ntype StateDB interface {\n\tGetBalance() int\n\tseal()\n}\n")},
+		Target: GoSources{"core/vm/interface.go": []byte("package vm\n\ntype StateDB interface {\n\tGetBalance() int\n\tGetNonce() int\n\tseal()\n}\n")},
+		QuorumOnly: GoSources{"private/private.go": []byte(
+			"package private\n\nimport \"github.com/ethereum/go-ethereum/core/vm\"\n\nvar db vm.StateDB\n")},
+	}
+	// the interface cannot be implemented outside of its package, a new method breaks no implementation
+	if breakages := GetAPIBreakages(sources); len(breakages) != 0 {
+		t.Errorf("GetAPIBreakages = %+v, want none", breakages)
+	}
+}
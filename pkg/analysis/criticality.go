@@ -0,0 +1,42 @@
+package analysis
+
+import (
+	"path"
+	"strings"
+
+	"upgradebot/config"
+)
+
+// getCriticalArea - first critical area matching a file
+func getCriticalArea(criticalAreas []config.CriticalArea, filename string) (config.CriticalArea, bool) {
+	for _, area := range criticalAreas {
+		if matchPathGlob(area.Path, filename) {
+			return area, true
+		}
+	}
+	return config.CriticalArea{}, false
+}
+
+// matchPathGlob - whether a file matches a glob of path.Match, where a `**` segment matches any number of directories
+func matchPathGlob(pattern string, filename string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(filename, "/"))
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	matched, err := path.Match(pattern[0], segments[0])
+	return err == nil && matched && matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,56 @@
+package analysis
+
+import (
+	"testing"
+
+	"upgradebot/config"
+)
+
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		filename string
+		want     bool
+	}{
+		{pattern: "core/state/**", filename: "core/state/statedb.go", want: true},
+		{pattern: "core/state/**", filename: "core/state/snapshot/journal.go", want: true},
+		{pattern: "core/state/**", filename: "core/state", want: true},
+		{pattern: "core/state/**", filename: "core/statedb.go", want: false},
+		{pattern: "core/*.go", filename: "core/blockchain.go", want: true},
+		{pattern: "core/*.go", filename: "core/vm/evm.go", want: false},
+		{pattern: "**/*_test.go", filename: "core/vm/evm_test.go", want: true},
+		{pattern: "**/*_test.go", filename: "main_test.go", want: true},
+		{pattern: "core/**/evm.go", filename: "core/evm.go", want: true},
+		{pattern: "core/**/evm.go", filename: "core/vm/runtime/evm.go", want: true},
+		{pattern: "eth/handler.go", filename: "eth/handler.go", want: true},
+		{pattern: "eth/handler.go", filename: "les/eth/handler.go", want: false},
+		{pattern: "eth/[", filename: "eth/[", want: false},
+	}
+	for _, test := range tests {
+		if got := matchPathGlob(test.pattern, test.filename); got != test.want {
+			t.Errorf("matchPathGlob(%q, %q) = %t, want %t", test.pattern, test.filename, got, test.want)
+		}
+	}
+}
+
+func TestGetCriticalArea(t *testing.T) {
+	areas := []config.CriticalArea{
+		{Path: "core/state/snapshot/**", Criticality: config.CriticalityMedium, Owner: "alice"},
+		{Path: "core/state/**", Criticality: config.CriticalityHigh, Owner: "bob"},
+	}
+	tests := []struct {
+		filename string
+		want     string
+		found    bool
+	}{
+		{filename: "core/state/snapshot/journal.go", want: "alice", found: true},
+		{filename: "core/state/statedb.go", want: "bob", found: true},
+		{filename: "core/blockchain.go", found: false},
+	}
+	for _, test := range tests {
+		area, found := getCriticalArea(areas, test.filename)
+		if found != test.found || area.Owner != test.want {
+			t.Errorf("getCriticalArea(%s) = %+v, %t, want the area of %q", test.filename, area, found, test.want)
+		}
+	}
+}
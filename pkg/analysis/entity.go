@@ -1,6 +1,7 @@
 package analysis
 
 import (
+	"upgradebot/config"
 	"upgradebot/pkg/github"
 )
 
@@ -18,16 +19,40 @@ type PullRequestStats struct {
 	TopPackagesChanged []PackageStats
 
 	Assessment Assessment
+	// Risk - score of the risk model, the higher the riskier
+	Risk float64
+	// Overlap - upstream hunks of the files of the PR overlapping or adjacent to Quorum changes
+	Overlap HunkOverlap
+	// Symbols - symbols changed upstream in the files of the PR that Quorum modified or uses
+	Symbols []SymbolImpact
+	// CriticalAreas - critical areas of the files of the PR, in the order of the config
+	CriticalAreas []config.CriticalArea
 }
 
 type Assessment string
 
 const (
-	Good     Assessment = "Good"
+	Good Assessment = "Good"
+	// SameFile - Quorum changed the file, in regions disjoint from the upstream changes
+	SameFile Assessment = "SameFile"
 	Warning  Assessment = "Warning"
 	Conflict Assessment = "Conflict"
 )
 
+// level - rank of the assessment, from Good (0) to Conflict
+func (a Assessment) level() int {
+	switch a {
+	case SameFile:
+		return 1
+	case Warning:
+		return 2
+	case Conflict:
+		return 3
+	default:
+		return 0
+	}
+}
+
 type PackageStats struct {
 	Name  string
 	Count int
@@ -37,9 +62,15 @@ type ChangedFileStats struct {
 	AssociatedPRs []github.PullRequestData
 	File          github.File
 	Assessment    Assessment
+	Risk          float64
+	Overlap       HunkOverlap
+	// ChangedByQuorum - whether Quorum changed the file too, whatever its assessment
+	ChangedByQuorum bool
 }
 
 type Analysis struct {
 	PrStats   []PullRequestStats
 	FileStats []ChangedFileStats
+	// APIBreakages - incompatible changes of the go-ethereum API used by Quorum
+	APIBreakages []APIBreakage
 }
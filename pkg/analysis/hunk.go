@@ -0,0 +1,143 @@
+package analysis
+
+import (
+	"upgradebot/pkg/git"
+)
+
+// adjacentLines - maximum number of unchanged lines between a Quorum hunk and an upstream hunk for them to be adjacent:
+// git needs unchanged lines between the changes of both sides to merge them, and nearby changes deserve a review anyway
+const adjacentLines = 3
+
+// HunkOverlap - number of upstream hunks of a file overlapping the hunks changed by Quorum, or adjacent to them
+type HunkOverlap struct {
+	Overlapping int
+	Adjacent    int
+}
+
+// IsDisjoint - whether upstream and Quorum changed disjoint regions of the file
+func (o HunkOverlap) IsDisjoint() bool {
+	return o.Overlapping == 0 && o.Adjacent == 0
+}
+
+func (o HunkOverlap) add(other HunkOverlap) HunkOverlap {
+	return HunkOverlap{Overlapping: o.Overlapping + other.Overlapping, Adjacent: o.Adjacent + other.Adjacent}
+}
+
+// GetHunkOverlaps - overlap of the upstream hunks with the Quorum hunks of each file changed by both,
+// the hunks of both sides being numbered in the same revision (the geth base tag)
+func GetHunkOverlaps(quorumHunks map[string][]git.Hunk, upstreamHunks map[string][]git.Hunk) map[string]HunkOverlap {
+	overlaps := make(map[string]HunkOverlap)
+	for file, upstream := range upstreamHunks {
+		quorum, ok := quorumHunks[file]
+		if !ok {
+			continue
+		}
+		overlap := HunkOverlap{}
+		for _, upstreamHunk := range upstream {
+			distance := -1
+			for _, quorumHunk := range quorum {
+				if d := hunkDistance(upstreamHunk, quorumHunk); distance < 0 || d < distance {
+					distance = d
+				}
+			}
+			switch {
+			case distance == 0:
+				overlap.Overlapping++
+			case distance > 0 && distance <= adjacentLines+1:
+				overlap.Adjacent++
+			}
+		}
+		overlaps[file] = overlap
+	}
+	return overlaps
+}
+
+// hunkDistance - 0 if the hunks overlap, otherwise the number of unchanged lines between them plus one.
+// A pure addition is between two lines, so it is at distance 1 of a hunk changing either of them.
+func hunkDistance(a git.Hunk, b git.Hunk) int {
+	aStart, aEnd := hunkBounds(a)
+	bStart, bEnd := hunkBounds(b)
+	if aStart <= bEnd && bStart <= aEnd {
+		return 0
+	}
+	gap := bStart - aEnd
+	if aStart > bEnd {
+		gap = aStart - bEnd
+	}
+	// the bounds are in half lines
+	return (gap + 1) / 2
+}
+
+// hunkBounds - first and last half line of the hunk: line n spans 2n, a pure addition after line n is 2n+1
+func hunkBounds(h git.Hunk) (int, int) {
+	if h.Count == 0 {
+		return 2*h.Start + 1, 2*h.Start + 1
+	}
+	return 2 * h.Start, 2 * h.End()
+}
+
+// UpgradeHunks - hunks changed by Quorum and upstream, all numbered in the geth tag merged into Quorum
+type UpgradeHunks struct {
+	// Quorum - hunks changed by Quorum since the base tag
+	Quorum map[string][]git.Hunk
+	// Upstream - hunks changed upstream by the upgrade
+	Upstream map[string][]git.Hunk
+	// PullRequests - hunks changed by each PR, by number. The files of the PRs without hunks are assessed with the Upstream hunks.
+	PullRequests map[int]map[string][]git.Hunk
+}
+
+// MapHunksToBase - hunks numbered in a revision numbered in the base instead, given the hunks changed from the base to
+// the revision. The lines changed since the base map to the lines they replaced, the other lines are shifted.
+func MapHunksToBase(hunks []git.Hunk, baseToRevision []git.Hunk) []git.Hunk {
+	mapped := make([]git.Hunk, 0, len(hunks))
+	for _, hunk := range hunks {
+		start, end := hunkBounds(hunk)
+		startLow, startHigh := mapHalfLineToBase(start, baseToRevision)
+		endLow, endHigh := mapHalfLineToBase(end, baseToRevision)
+		low, high := startLow, endHigh
+		if endLow < low {
+			low = endLow
+		}
+		if startHigh > high {
+			high = startHigh
+		}
+		mapped = append(mapped, hunkFromBounds(low, high))
+	}
+	return mapped
+}
+
+// mapHalfLineToBase - half lines of the base (see hunkBounds) of a half line of the revision:
+// a line changed since the base maps to the lines its hunk replaced, as does a position between two changed lines
+func mapHalfLineToBase(halfLine int, baseToRevision []git.Hunk) (int, int) {
+	line := halfLine / 2
+	delta := 0
+	for _, change := range baseToRevision {
+		if change.NewCount > 0 && change.NewStart <= line && line <= change.NewStart+change.NewCount-1 {
+			lastNewLine := change.NewStart + change.NewCount - 1
+			if halfLine%2 == 0 || line < lastNewLine {
+				return hunkBounds(change)
+			}
+			// between the last line of the change and the next unchanged line
+			after := change.Start
+			if change.Count > 0 {
+				after = change.End()
+			}
+			return 2*after + 1, 2*after + 1
+		}
+		// changes before the line, a pure deletion being after the line NewStart
+		if (change.NewCount > 0 && change.NewStart+change.NewCount-1 < line) || (change.NewCount == 0 && change.NewStart < line) {
+			delta += change.NewCount - change.Count
+		}
+	}
+	base := halfLine - 2*delta
+	return base, base
+}
+
+// hunkFromBounds - hunk of the lines between two half lines, a pure addition if there is none
+func hunkFromBounds(low int, high int) git.Hunk {
+	start, end := (low+1)/2, high/2
+	if start > end {
+		return git.Hunk{Start: end}
+	}
+	return git.Hunk{Start: start, Count: end - start + 1}
+}
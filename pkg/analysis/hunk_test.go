@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"upgradebot/pkg/git"
+)
+
+func TestGetHunkOverlaps(t *testing.T) {
+	quorum := map[string][]git.Hunk{
+		"core/a.go": {{Start: 10, Count: 2}},
+		"core/b.go": {{Start: 10, Count: 1}},
+		"core/c.go": {{Start: 5}},
+		// changed by Quorum only
+		"core/d.go": {{Start: 1, Count: 1}},
+	}
+	upstream := map[string][]git.Hunk{
+		// overlapping, adjacent after the last unchanged line and disjoint
+		"core/a.go": {{Start: 11, Count: 1}, {Start: 15, Count: 1}, {Start: 16, Count: 1}},
+		// additions right after the changed line and 3 lines before it, a deletion far away
+		"core/b.go": {{Start: 10}, {Start: 6}, {Start: 30, Count: 3}},
+		// an addition at the same place, and a change of the lines around it
+		"core/c.go": {{Start: 5}, {Start: 5, Count: 2}},
+		// changed by upstream only
+		"core/e.go": {{Start: 1, Count: 1}},
+	}
+	want := map[string]HunkOverlap{
+		"core/a.go": {Overlapping: 1, Adjacent: 1},
+		"core/b.go": {Adjacent: 2},
+		"core/c.go": {Overlapping: 2},
+	}
+
+	overlaps := GetHunkOverlaps(quorum, upstream)
+	if !reflect.DeepEqual(overlaps, want) {
+		t.Errorf("GetHunkOverlaps = %v, want %v", overlaps, want)
+	}
+	if overlaps["core/a.go"].IsDisjoint() || !(HunkOverlap{}).IsDisjoint() {
+		t.Errorf("IsDisjoint of %v", overlaps["core/a.go"])
+	}
+}
+
+func TestHunkDistance(t *testing.T) {
+	tests := []struct {
+		a, b git.Hunk
+		want int
+	}{
+		{a: git.Hunk{Start: 1, Count: 3}, b: git.Hunk{Start: 3, Count: 1}, want: 0},
+		{a: git.Hunk{Start: 1, Count: 3}, b: git.Hunk{Start: 4, Count: 1}, want: 1},
+		{a: git.Hunk{Start: 1, Count: 3}, b: git.Hunk{Start: 8, Count: 1}, want: 5},
+		{a: git.Hunk{Start: 8, Count: 1}, b: git.Hunk{Start: 1, Count: 3}, want: 5},
+		{a: git.Hunk{Start: 3}, b: git.Hunk{Start: 3, Count: 1}, want: 1},
+		{a: git.Hunk{Start: 3}, b: git.Hunk{Start: 4, Count: 1}, want: 1},
+		{a: git.Hunk{Start: 3}, b: git.Hunk{Start: 3}, want: 0},
+		{a: git.Hunk{Start: 3}, b: git.Hunk{Start: 3, Count: 2}, want: 0},
+	}
+	for _, test := range tests {
+		if got := hunkDistance(test.a, test.b); got != test.want {
+			t.Errorf("hunkDistance(%v, %v) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestMapHunksToBase(t *testing.T) {
+	baseToRevision := []git.Hunk{
+		// line 3 replaced by two lines
+		{Start: 3, Count: 1, NewStart: 3, NewCount: 2},
+		// lines 10 and 11 deleted
+		{Start: 10, Count: 2, NewStart: 10},
+		// a line added after line 20
+		{Start: 20, NewStart: 20, NewCount: 1},
+	}
+	tests := []struct {
+		name string
+		hunk git.Hunk
+		want git.Hunk
+	}{
+		{name: "before the changes", hunk: git.Hunk{Start: 1, Count: 1}, want: git.Hunk{Start: 1, Count: 1}},
+		{name: "changed line", hunk: git.Hunk{Start: 4, Count: 1}, want: git.Hunk{Start: 3, Count: 1}},
+		{name: "all the changed lines", hunk: git.Hunk{Start: 3, Count: 2}, want: git.Hunk{Start: 3, Count: 1}},
+		{name: "from an unchanged line to a changed line", hunk: git.Hunk{Start: 2, Count: 3}, want: git.Hunk{Start: 2, Count: 2}},
+		{name: "after an addition", hunk: git.Hunk{Start: 6, Count: 1}, want: git.Hunk{Start: 5, Count: 1}},
+		{name: "addition after an addition", hunk: git.Hunk{Start: 6}, want: git.Hunk{Start: 5}},
+		{name: "addition after a changed line", hunk: git.Hunk{Start: 4}, want: git.Hunk{Start: 3}},
+		{name: "after a deletion", hunk: git.Hunk{Start: 12, Count: 2}, want: git.Hunk{Start: 13, Count: 2}},
+		{name: "added line", hunk: git.Hunk{Start: 20, Count: 1}, want: git.Hunk{Start: 20}},
+		{name: "after all the changes", hunk: git.Hunk{Start: 30, Count: 1}, want: git.Hunk{Start: 30, Count: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mapped := MapHunksToBase([]git.Hunk{test.hunk}, baseToRevision)
+			if !reflect.DeepEqual(mapped, []git.Hunk{test.want}) {
+				t.Errorf("MapHunksToBase(%v) = %v, want %v", test.hunk, mapped, test.want)
+			}
+		})
+	}
+	if mapped := MapHunksToBase([]git.Hunk{{Start: 5, Count: 1}}, nil); !reflect.DeepEqual(mapped, []git.Hunk{{Start: 5, Count: 1}}) {
+		t.Errorf("MapHunksToBase without changes = %v", mapped)
+	}
+}
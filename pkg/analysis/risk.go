@@ -0,0 +1,76 @@
+package analysis
+
+import (
+	"math"
+	"strings"
+
+	"upgradebot/config"
+)
+
+// RiskFactors - factors of the risk of a changed file, or of a PR summing the factors of its files
+type RiskFactors struct {
+	LinesChanged int
+	// QuorumFiles - files changed by Quorum too
+	QuorumFiles int
+	// ConflictFiles - files with merge conflicts
+	ConflictFiles    int
+	OverlappingHunks int
+	AdjacentHunks    int
+	// ImpactedSymbols - symbols changed upstream that Quorum modified or uses
+	ImpactedSymbols int
+	// HighCriticalityFiles and MediumCriticalityFiles - package criticality: files of the critical areas of high and medium criticality
+	HighCriticalityFiles   int
+	MediumCriticalityFiles int
+	// TestOnly - whether only test files are changed
+	TestOnly bool
+}
+
+func (f RiskFactors) add(other RiskFactors) RiskFactors {
+	return RiskFactors{
+		LinesChanged:           f.LinesChanged + other.LinesChanged,
+		QuorumFiles:            f.QuorumFiles + other.QuorumFiles,
+		ConflictFiles:          f.ConflictFiles + other.ConflictFiles,
+		OverlappingHunks:       f.OverlappingHunks + other.OverlappingHunks,
+		AdjacentHunks:          f.AdjacentHunks + other.AdjacentHunks,
+		ImpactedSymbols:        f.ImpactedSymbols + other.ImpactedSymbols,
+		HighCriticalityFiles:   f.HighCriticalityFiles + other.HighCriticalityFiles,
+		MediumCriticalityFiles: f.MediumCriticalityFiles + other.MediumCriticalityFiles,
+		TestOnly:               f.TestOnly && other.TestOnly,
+	}
+}
+
+// RiskModel - scoring model of the risk of the PRs and changed files, the higher the riskier
+type RiskModel interface {
+	Score(factors RiskFactors) float64
+}
+
+// WeightedRiskModel - weighted sum of the risk factors, the lines changed counting logarithmically,
+// and the package criticality with a weight per level
+type WeightedRiskModel struct {
+	Weights config.RiskWeights
+}
+
+// NewRiskModel - risk model weighting the factors with the weights of the config
+func NewRiskModel(weights config.RiskWeights) RiskModel {
+	return &WeightedRiskModel{Weights: weights}
+}
+
+func (m *WeightedRiskModel) Score(factors RiskFactors) float64 {
+	score := m.Weights.LinesChanged*math.Log2(1+float64(factors.LinesChanged)) +
+		m.Weights.QuorumFile*float64(factors.QuorumFiles) +
+		m.Weights.ConflictFile*float64(factors.ConflictFiles) +
+		m.Weights.OverlappingHunk*float64(factors.OverlappingHunks) +
+		m.Weights.AdjacentHunk*float64(factors.AdjacentHunks) +
+		m.Weights.ImpactedSymbol*float64(factors.ImpactedSymbols) +
+		m.Weights.HighCriticalityFile*float64(factors.HighCriticalityFiles) +
+		m.Weights.MediumCriticalityFile*float64(factors.MediumCriticalityFiles)
+	if factors.TestOnly {
+		score *= m.Weights.TestOnly
+	}
+	return score
+}
+
+// isTestFile - Go test files and test data
+func isTestFile(filename string) bool {
+	return strings.HasSuffix(filename, "_test.go") || strings.HasPrefix(filename, "testdata/") || strings.Contains(filename, "/testdata/")
+}
@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+
+	"upgradebot/config"
+)
+
+func TestWeightedRiskModelScore(t *testing.T) {
+	model := NewRiskModel(config.Default().RiskWeights)
+	tests := []struct {
+		name    string
+		factors RiskFactors
+		want    float64
+	}{
+		{name: "no change", factors: RiskFactors{}, want: 0},
+		{name: "lines changed count logarithmically", factors: RiskFactors{LinesChanged: 7}, want: 3},
+		{name: "file changed by Quorum", factors: RiskFactors{LinesChanged: 1, QuorumFiles: 1}, want: 1 + 5},
+		{
+			name: "all the factors",
+			factors: RiskFactors{
+				LinesChanged: 3, QuorumFiles: 2, ConflictFiles: 1, OverlappingHunks: 2, AdjacentHunks: 3,
+				ImpactedSymbols: 4, HighCriticalityFiles: 1, MediumCriticalityFiles: 2,
+			},
+			want: 2 + 2*5 + 20 + 2*3 + 3 + 4*2 + 10 + 2*3,
+		},
+		{name: "high criticality", factors: RiskFactors{HighCriticalityFiles: 2}, want: 20},
+		{name: "medium criticality", factors: RiskFactors{MediumCriticalityFiles: 2}, want: 6},
+		{name: "test files only", factors: RiskFactors{LinesChanged: 15, ConflictFiles: 1, TestOnly: true}, want: (4 + 20) * 0.2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if score := model.Score(test.factors); math.Abs(score-test.want) > 1e-9 {
+				t.Errorf("Score(%+v) = %v, want %v", test.factors, score, test.want)
+			}
+		})
+	}
+}
+
+func TestRiskFactorsAdd(t *testing.T) {
+	a := RiskFactors{LinesChanged: 1, QuorumFiles: 1, HighCriticalityFiles: 1, TestOnly: true}
+	b := RiskFactors{LinesChanged: 2, ConflictFiles: 1, MediumCriticalityFiles: 1, ImpactedSymbols: 3, TestOnly: true}
+	want := RiskFactors{LinesChanged: 3, QuorumFiles: 1, ConflictFiles: 1, ImpactedSymbols: 3,
+		HighCriticalityFiles: 1, MediumCriticalityFiles: 1, TestOnly: true}
+	if sum := a.add(b); sum != want {
+		t.Errorf("add = %+v, want %+v", sum, want)
+	}
+	// a PR changing a test file and another file is not test only
+	if sum := a.add(RiskFactors{LinesChanged: 1}); sum.TestOnly {
+		t.Errorf("add of a file that is not a test = %+v, want not test only", sum)
+	}
+}
+
+func TestIsTestFile(t *testing.T) {
+	tests := map[string]bool{
+		"core/blockchain_test.go":      true,
+		"testdata/genesis.json":        true,
+		"core/vm/testdata/precompiles": true,
+		"core/blockchain.go":           false,
+		"core/testing.go":              false,
+	}
+	for filename, want := range tests {
+		if got := isTestFile(filename); got != want {
+			t.Errorf("isTestFile(%s) = %t, want %t", filename, got, want)
+		}
+	}
+}
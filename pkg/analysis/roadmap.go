@@ -0,0 +1,47 @@
+package analysis
+
+import (
+	"upgradebot/pkg/github"
+)
+
+// RoadmapStep - summary of the upgrade from the previous release of the roadmap to a release
+type RoadmapStep struct {
+	Release github.ReleaseData
+
+	PullRequestCount int
+	ChangedFileCount int
+	// OverlapCount - files changed by the release that Quorum changed too
+	OverlapCount int
+	// ConflictCount - files changed by the release with conflicts when merging the release into Quorum
+	ConflictCount int
+	// ImpactedSymbolCount - symbols changed by the release that Quorum modified or uses
+	ImpactedSymbolCount int
+	// MergeConflictCount - all the files with conflicts when merging the release into Quorum, whatever the release that changed them
+	MergeConflictCount int
+}
+
+// GetRoadmapStep - summarize the analysis of the changes of a release, expectedFileConflicts being the conflicts of its merge into Quorum
+func GetRoadmapStep(release github.ReleaseData, analysis Analysis, expectedFileConflicts []string) RoadmapStep {
+	step := RoadmapStep{
+		Release:            release,
+		PullRequestCount:   len(analysis.PrStats),
+		ChangedFileCount:   len(analysis.FileStats),
+		MergeConflictCount: len(expectedFileConflicts),
+	}
+	for _, stats := range analysis.FileStats {
+		if stats.ChangedByQuorum {
+			step.OverlapCount++
+		}
+		if stats.Assessment == Conflict {
+			step.ConflictCount++
+		}
+	}
+	symbols := make(map[string]bool)
+	for _, stats := range analysis.PrStats {
+		for _, impact := range stats.Symbols {
+			symbols[impact.Name] = true
+		}
+	}
+	step.ImpactedSymbolCount = len(symbols)
+	return step
+}
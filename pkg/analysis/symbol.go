@@ -0,0 +1,394 @@
+package analysis
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"upgradebot/pkg/git"
+)
+
+// gethModulePath - module of go-ethereum, and of Quorum which keeps it
+const gethModulePath = "github.com/ethereum/go-ethereum"
+
+// GoSources - contents of Go files by path, at a revision
+type GoSources map[string][]byte
+
+// SymbolSources - Go files needed by the symbol analysis, the other files of the revisions are not read.
+// The base tag is the geth tag merged into Quorum, unless a roadmap compares a later release to the next one.
+type SymbolSources struct {
+	// Base - files changed upstream, at the base geth tag
+	Base GoSources
+	// Target - files changed upstream, at the target geth tag
+	Target GoSources
+	// Quorum - files changed upstream and by Quorum, at the Quorum HEAD
+	Quorum GoSources
+	// QuorumOnly - files added by Quorum (private transactions, permissioning, raft, IBFT...), at the Quorum HEAD
+	QuorumOnly GoSources
+	// QuorumBase - files changed upstream and by Quorum at the geth tag merged into Quorum, nil if it is the base tag
+	QuorumBase GoSources
+	// BaseHunks - hunks changed upstream from the geth tag merged into Quorum to the base tag, nil if it is the base tag
+	BaseHunks map[string][]git.Hunk
+	// TargetHunks - hunks changed upstream from the geth tag merged into Quorum to the target tag
+	TargetHunks map[string][]git.Hunk
+}
+
+type SymbolChange string
+
+const (
+	SymbolAdded    SymbolChange = "Added"
+	SymbolModified SymbolChange = "Modified"
+	SymbolRemoved  SymbolChange = "Removed"
+)
+
+// SymbolImpact - function, method or type changed upstream that Quorum modified too, or uses from its own files
+type SymbolImpact struct {
+	// Name - package directory and name of the symbol, with the receiver type for a method (e.g. core.BlockChain.InsertChain)
+	Name   string
+	File   string
+	Change SymbolChange
+	// ModifiedByQuorum - Quorum changed the symbol of the geth tag it merged, or added one with the same name as an upstream addition
+	ModifiedByQuorum bool
+	// QuorumFiles - files added by Quorum using the symbol
+	QuorumFiles []string
+	// BaseLines - lines of the declaration numbered in the geth tag merged into Quorum, or where an added symbol is inserted,
+	// to find the PRs changing it
+	BaseLines git.Hunk
+}
+
+// symbol - top-level function, method or type of a Go file
+type symbol struct {
+	// source - declaration without its doc comment, to detect the changes
+	source string
+	// lines - lines of the declaration
+	lines git.Hunk
+}
+
+// fileSymbols - symbols of a Go file and its package name
+type fileSymbols struct {
+	packageName string
+	symbols     map[string]symbol
+}
+
+// GetSymbolImpacts - symbols changed upstream between the base and the target tag that Quorum modified or uses, by file.
+// The uses are found by name, without type checking: a method is used by a Quorum file calling a method with its name
+// and referencing its package. A file that cannot be parsed is skipped with a warning, the other files are still analysed.
+func GetSymbolImpacts(sources SymbolSources) map[string][]SymbolImpact {
+	files := make(map[string]bool)
+	for file := range sources.Base {
+		files[file] = true
+	}
+	for file := range sources.Target {
+		files[file] = true
+	}
+
+	// package names of the directories, to resolve the imports of the Quorum files
+	packageNames := make(map[string]string)
+	changes := make(map[string]map[string]SymbolChange)
+	baseSymbols := make(map[string]fileSymbols)
+	targetSymbols := make(map[string]fileSymbols)
+	for file := range files {
+		base, err := parseSymbols(file, sources.Base[file])
+		if err != nil {
+			log.Printf("Skipping %s in the symbol analysis: %v\n", file, err)
+			continue
+		}
+		target, err := parseSymbols(file, sources.Target[file])
+		if err != nil {
+			log.Printf("Skipping %s in the symbol analysis: %v\n", file, err)
+			continue
+		}
+		addPackageName(packageNames, file, base, target)
+		changes[file] = diffSymbols(base.symbols, target.symbols)
+		baseSymbols[file] = base
+		targetSymbols[file] = target
+	}
+
+	references := parseQuorumReferences(sources.QuorumOnly, packageNames)
+
+	impacts := make(map[string][]SymbolImpact)
+	for file, fileChanges := range changes {
+		// Quorum changed the symbols of the geth tag it merged
+		quorumBaseSymbols := baseSymbols[file].symbols
+		if sources.QuorumBase != nil {
+			if quorumBase, err := parseSymbols(file, sources.QuorumBase[file]); err != nil {
+				log.Printf("Skipping the Quorum version of %s in the symbol analysis: %v\n", file, err)
+				quorumBaseSymbols = nil
+			} else {
+				quorumBaseSymbols = quorumBase.symbols
+			}
+		}
+		var quorumSymbols map[string]symbol
+		if content, ok := sources.Quorum[file]; ok && quorumBaseSymbols != nil {
+			// the symbols of a Quorum file that cannot be parsed are not compared, their uses are still reported
+			if quorum, err := parseSymbols(file, content); err != nil {
+				log.Printf("Skipping the Quorum version of %s in the symbol analysis: %v\n", file, err)
+			} else {
+				quorumSymbols = quorum.symbols
+			}
+		}
+
+		dir := path.Dir(file)
+		for name, change := range fileChanges {
+			impact := SymbolImpact{Name: symbolName(dir, name), File: file, Change: change}
+			if base, inBase := baseSymbols[file].symbols[name]; inBase {
+				impact.BaseLines = MapHunksToBase([]git.Hunk{base.lines}, sources.BaseHunks[file])[0]
+			} else {
+				impact.BaseLines = MapHunksToBase([]git.Hunk{targetSymbols[file].symbols[name].lines}, sources.TargetHunks[file])[0]
+			}
+			if quorumSymbols != nil {
+				quorumBase, inQuorumBase := quorumBaseSymbols[name]
+				quorum, inQuorum := quorumSymbols[name]
+				impact.ModifiedByQuorum = (inQuorumBase && (!inQuorum || quorum.source != quorumBase.source)) || (!inQuorumBase && inQuorum)
+			}
+			impact.QuorumFiles = referencedFiles(references.uses(dir, name))
+			if impact.ModifiedByQuorum || len(impact.QuorumFiles) > 0 {
+				impacts[file] = append(impacts[file], impact)
+			}
+		}
+		sort.Slice(impacts[file], func(i, j int) bool {
+			return impacts[file][i].Name < impacts[file][j].Name
+		})
+	}
+	return impacts
+}
+
+// diffSymbols - symbols added, modified or removed between two versions of a file
+func diffSymbols(base map[string]symbol, target map[string]symbol) map[string]SymbolChange {
+	changes := make(map[string]SymbolChange)
+	for name, baseSymbol := range base {
+		targetSymbol, ok := target[name]
+		if !ok {
+			changes[name] = SymbolRemoved
+		} else if targetSymbol.source != baseSymbol.source {
+			changes[name] = SymbolModified
+		}
+	}
+	for name := range target {
+		if _, ok := base[name]; !ok {
+			changes[name] = SymbolAdded
+		}
+	}
+	return changes
+}
+
+// addPackageName - package name of the directory of a file, from any of its parsed versions
+func addPackageName(packageNames map[string]string, file string, versions ...fileSymbols) {
+	for _, parsed := range versions {
+		if parsed.packageName != "" {
+			packageNames[path.Dir(file)] = parsed.packageName
+		}
+	}
+}
+
+// symbolName - name of a symbol of a directory for the report, e.g. core/types.Header
+func symbolName(dir string, name string) string {
+	if dir == "." {
+		return name
+	}
+	return dir + "." + name
+}
+
+// parseSymbols - top-level functions, methods and types of a Go file, none for an absent file
+func parseSymbols(file string, content []byte) (fileSymbols, error) {
+	parsed := fileSymbols{symbols: make(map[string]symbol)}
+	if content == nil {
+		return parsed, nil
+	}
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, file, content, 0)
+	if err != nil {
+		return parsed, fmt.Errorf("parse %s: %w", file, err)
+	}
+	parsed.packageName = astFile.Name.Name
+
+	declaration := func(node ast.Node) symbol {
+		start, end := fset.Position(node.Pos()), fset.Position(node.End())
+		return symbol{
+			source: string(content[start.Offset:end.Offset]),
+			lines:  git.Hunk{Start: start.Line, Count: end.Line - start.Line + 1},
+		}
+	}
+	for _, decl := range astFile.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			name := decl.Name.Name
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				name = receiverTypeName(decl.Recv.List[0].Type) + "." + name
+			}
+			parsed.symbols[name] = declaration(decl)
+		case *ast.GenDecl:
+			if decl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				parsed.symbols[typeSpec.Name.Name] = declaration(typeSpec)
+			}
+		}
+	}
+	return parsed, nil
+}
+
+// receiverTypeName - name of the type of a method receiver, without pointer nor type parameters
+func receiverTypeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(expr.X)
+	case *ast.ParenExpr:
+		return receiverTypeName(expr.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	default:
+		return ""
+	}
+}
+
+// reference - line of a file added by Quorum using an identifier
+type reference struct {
+	file string
+	line int
+}
+
+// quorumReferences - references of the files added by Quorum to the go-ethereum packages, found by name
+type quorumReferences struct {
+	// identifiers - references by `dir:name` to the identifiers of a package
+	identifiers map[string][]reference
+	// selectors - references by name to the methods called, or fields selected, on any value
+	selectors map[string][]reference
+	// packages - files referencing each package directory
+	packages map[string]map[string]bool
+}
+
+// parseQuorumReferences - references of the files added by Quorum, the package names of the directories resolve their imports.
+// The files that cannot be parsed are skipped with a warning.
+func parseQuorumReferences(quorumOnly GoSources, packageNames map[string]string) quorumReferences {
+	references := quorumReferences{
+		identifiers: make(map[string][]reference),
+		selectors:   make(map[string][]reference),
+		packages:    make(map[string]map[string]bool),
+	}
+	files := make([]string, 0, len(quorumOnly))
+	for file := range quorumOnly {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		if err := references.parseFile(file, quorumOnly[file], packageNames); err != nil {
+			log.Printf("Skipping the references of %s: %v\n", file, err)
+		}
+	}
+	return references
+}
+
+// uses - references to a symbol of a directory, sorted by file and line.
+// The references of a method or field are the selections of its name in the files referencing its package.
+func (r quorumReferences) uses(dir string, name string) []reference {
+	var uses []reference
+	if dot := strings.Index(name, "."); dot >= 0 {
+		for _, ref := range r.selectors[name[dot+1:]] {
+			if r.packages[dir][ref.file] {
+				uses = append(uses, ref)
+			}
+		}
+	} else {
+		uses = append(uses, r.identifiers[dir+":"+name]...)
+	}
+	sort.SliceStable(uses, func(i, j int) bool {
+		if uses[i].file != uses[j].file {
+			return uses[i].file < uses[j].file
+		}
+		return uses[i].line < uses[j].line
+	})
+	return uses
+}
+
+// referencedFiles - files of sorted references, without duplicates
+func referencedFiles(uses []reference) []string {
+	var files []string
+	for i, ref := range uses {
+		if i == 0 || ref.file != uses[i-1].file {
+			files = append(files, ref.file)
+		}
+	}
+	return files
+}
+
+func (r quorumReferences) parseFile(file string, content []byte, packageNames map[string]string) error {
+	fset := token.NewFileSet()
+	astFile, err := parser.ParseFile(fset, file, content, 0)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", file, err)
+	}
+
+	// the identifiers declared neither in the file nor imported are the ones of its own package, or of a dot import
+	unqualifiedDirs := []string{path.Dir(file)}
+	imports := make(map[string]string)
+	for _, importSpec := range astFile.Imports {
+		importPath, err := strconv.Unquote(importSpec.Path.Value)
+		if err != nil || !strings.HasPrefix(importPath, gethModulePath+"/") {
+			continue
+		}
+		dir := strings.TrimPrefix(importPath, gethModulePath+"/")
+		name, ok := packageNames[dir]
+		if !ok {
+			name = path.Base(dir)
+		}
+		if importSpec.Name != nil {
+			name = importSpec.Name.Name
+		}
+		switch name {
+		case "_":
+		case ".":
+			unqualifiedDirs = append(unqualifiedDirs, dir)
+		default:
+			imports[name] = dir
+		}
+	}
+
+	addIdentifier := func(dir string, ident *ast.Ident) {
+		if r.packages[dir] == nil {
+			r.packages[dir] = make(map[string]bool)
+		}
+		r.packages[dir][file] = true
+		key := dir + ":" + ident.Name
+		r.identifiers[key] = append(r.identifiers[key], reference{file: file, line: fset.Position(ident.Pos()).Line})
+	}
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.SelectorExpr:
+			if ident, ok := node.X.(*ast.Ident); ok && ident.Obj == nil {
+				if dir, ok := imports[ident.Name]; ok {
+					addIdentifier(dir, node.Sel)
+					return false
+				}
+			}
+			r.selectors[node.Sel.Name] = append(r.selectors[node.Sel.Name], reference{file: file, line: fset.Position(node.Sel.Pos()).Line})
+			ast.Inspect(node.X, visit)
+			return false
+		case *ast.Ident:
+			if node.Obj == nil {
+				for _, dir := range unqualifiedDirs {
+					addIdentifier(dir, node)
+				}
+			}
+		}
+		return true
+	}
+	for _, decl := range astFile.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			continue
+		}
+		ast.Inspect(decl, visit)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+
+	"upgradebot/pkg/git"
+)
+
+const symbolBase = `package core
+
+type BlockChain struct{}
+
+func (bc *BlockChain) InsertChain() int {
+	return 1
+}
+
+func Removed() {}
+
+func Unchanged() {}
+
+func Untouched() int { return 0 }
+`
+
+const symbolTarget = `package core
+
+type BlockChain struct{}
+
+func (bc *BlockChain) InsertChain() int {
+	return 2
+}
+
+func Unchanged() {}
+
+func Untouched() int { return 1 }
+
+func Added() {}
+`
+
+// symbolQuorum - Quorum changed InsertChain and added a function with the name of an upstream addition
+const symbolQuorum = `package core
+
+type BlockChain struct{}
+
+func (bc *BlockChain) InsertChain() int {
+	return private()
+}
+
+func Removed() {}
+
+func Unchanged() {}
+
+func Untouched() int { return 0 }
+
+func Added() {}
+`
+
+const symbolPrivate = `package private
+
+import "github.com/ethereum/go-ethereum/core"
+
+func Use(bc *core.BlockChain) {
+	core.Removed()
+	bc.InsertChain()
+	core.Unchanged()
+}
+`
+
+// symbolTargetHunks - hunks of the diff from symbolBase to symbolTarget
+var symbolTargetHunks = map[string][]git.Hunk{
+	"core/blockchain.go": {
+		{Start: 6, Count: 1, NewStart: 6, NewCount: 1},
+		{Start: 9, Count: 2, NewStart: 8},
+		{Start: 13, Count: 1, NewStart: 11, NewCount: 3},
+	},
+}
+
+func TestGetSymbolImpacts(t *testing.T) {
+	sources := SymbolSources{
+		Base:   GoSources{"core/blockchain.go": []byte(symbolBase), "core/broken.go": []byte("package core\n\nfunc {")},
+		Target: GoSources{"core/blockchain.go": []byte(symbolTarget), "core/broken.go": []byte("package core\n")},
+		Quorum: GoSources{"core/blockchain.go": []byte(symbolQuorum)},
+		QuorumOnly: GoSources{
+			"private/private.go": []byte(symbolPrivate),
+			"private/broken.go":  []byte("package private\n\nfunc {"),
+		},
+		TargetHunks: symbolTargetHunks,
+	}
+	want := map[string][]SymbolImpact{
+		"core/blockchain.go": {
+			{Name: "core.Added", File: "core/blockchain.go", Change: SymbolAdded, ModifiedByQuorum: true,
+				BaseLines: git.Hunk{Start: 13, Count: 1}},
+			{Name: "core.BlockChain.InsertChain", File: "core/blockchain.go", Change: SymbolModified, ModifiedByQuorum: true,
+				QuorumFiles: []string{"private/private.go"}, BaseLines: git.Hunk{Start: 5, Count: 3}},
+			{Name: "core.Removed", File: "core/blockchain.go", Change: SymbolRemoved,
+				QuorumFiles: []string{"private/private.go"}, BaseLines: git.Hunk{Start: 9, Count: 1}},
+		},
+	}
+	if impacts := GetSymbolImpacts(sources); !reflect.DeepEqual(impacts, want) {
+		t.Errorf("GetSymbolImpacts = %+v, want %+v", impacts, want)
+	}
+}
+
+// TestGetSymbolImpactsFromLaterBase - a roadmap step from a release after the geth tag merged into Quorum:
+// Quorum changes are compared to the merged tag, and the lines are numbered in it
+func TestGetSymbolImpactsFromLaterBase(t *testing.T) {
+	sources := SymbolSources{
+		Base:       GoSources{"core/blockchain.go": []byte(symbolBase)},
+		Target:     GoSources{"core/blockchain.go": []byte(symbolTarget)},
+		Quorum:     GoSources{"core/blockchain.go": []byte(symbolQuorum)},
+		QuorumOnly: GoSources{"private/private.go": []byte(symbolPrivate)},
+		// Quorum did not change the file of the merged tag
+		QuorumBase: GoSources{"core/blockchain.go": []byte(symbolQuorum)},
+		// two lines added after the first one from the merged tag to the base
+		BaseHunks:   map[string][]git.Hunk{"core/blockchain.go": {{Start: 1, NewStart: 2, NewCount: 2}}},
+		TargetHunks: map[string][]git.Hunk{},
+	}
+	want := map[string][]SymbolImpact{
+		"core/blockchain.go": {
+			{Name: "core.BlockChain.InsertChain", File: "core/blockchain.go", Change: SymbolModified,
+				QuorumFiles: []string{"private/private.go"}, BaseLines: git.Hunk{Start: 3, Count: 3}},
+			{Name: "core.Removed", File: "core/blockchain.go", Change: SymbolRemoved,
+				QuorumFiles: []string{"private/private.go"}, BaseLines: git.Hunk{Start: 7, Count: 1}},
+		},
+	}
+	if impacts := GetSymbolImpacts(sources); !reflect.DeepEqual(impacts, want) {
+		t.Errorf("GetSymbolImpacts = %+v, want %+v", impacts, want)
+	}
+}
@@ -0,0 +1,103 @@
+package git_test
+
+import (
+	"os"
+	osexec "os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"upgradebot/config"
+	"upgradebot/pkg/git"
+	"upgradebot/pkg/git/exec"
+	"upgradebot/pkg/git/gitfixture"
+	"upgradebot/pkg/git/gogit"
+)
+
+// TestBackends - the operations of the Git interface, run against every backend on the same fork
+func TestBackends(t *testing.T) {
+	backends := []struct {
+		name   string
+		newGit func(cfg *config.Config) git.Git
+		// conflicts - whether the backend is a ConflictDetector
+		conflicts bool
+	}{
+		{name: config.GitBackendExec, newGit: exec.NewGit, conflicts: true},
+		{name: config.GitBackendGoGit, newGit: gogit.NewGit},
+		{name: config.GitBackendGoGit + " in memory", newGit: gogit.NewInMemoryGit},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			f := gitfixture.NewForkScenario(t, map[string]string{"private/private.go": "package private\n"})
+			if err := f.DownstreamRename("core/blockchain.go", "core/chain.go"); err != nil {
+				t.Fatal(err)
+			}
+			cfg := config.Default()
+			f.Configure(cfg)
+			s := backend.newGit(cfg)
+			if err := s.CloneQuorumRepository(); err != nil {
+				t.Fatalf("CloneQuorumRepository: %v", err)
+			}
+			defer s.ClearQuorumRepository()
+
+			if _, ok := s.(git.ConflictDetector); ok != backend.conflicts {
+				t.Errorf("ConflictDetector: %t, want %t", ok, backend.conflicts)
+			}
+			baseTag, err := s.GetBaseGethTag()
+			if err != nil || baseTag != "v1.0.0" {
+				t.Errorf("GetBaseGethTag = %q, %v, want v1.0.0", baseTag, err)
+			}
+			// a geth tag released after the clone is not fetched
+			if err := f.UpstreamRelease("v1.0.2", map[string]string{"eth/handler.go": "package eth\n"}); err != nil {
+				t.Fatal(err)
+			}
+			mergedTag, err := s.GetMergedGethTag()
+			if err != nil || mergedTag != "v1.0.0" {
+				t.Errorf("GetMergedGethTag = %q, %v, want v1.0.0", mergedTag, err)
+			}
+			// a renamed file is changed under both names
+			files, err := s.GetChangedFilesAgainstGethBaseVersion("v1.0.0")
+			wantFiles := []string{"core/blockchain.go", "core/chain.go", "private/private.go"}
+			if err != nil || !reflect.DeepEqual(files, wantFiles) {
+				t.Errorf("GetChangedFilesAgainstGethBaseVersion = %v, %v, want %v", files, err, wantFiles)
+			}
+			hunks, err := s.GetChangedHunks("v1.0.0", "v1.0.1", "core/blockchain.go")
+			want := map[string][]git.Hunk{"core/blockchain.go": {{Start: 3, Count: 1, NewStart: 3, NewCount: 1}}}
+			if err != nil || !reflect.DeepEqual(hunks, want) {
+				t.Errorf("GetChangedHunks = %v, %v, want %v", hunks, err, want)
+			}
+
+			if err := s.CreateBranchFromGethTag("v1.0.1", "upgrade/v1.0.1"); err != nil {
+				t.Fatalf("CreateBranchFromGethTag: %v", err)
+			}
+			pushed, err := f.BotBranches()
+			if err != nil || !reflect.DeepEqual(pushed, []string{"upgrade/v1.0.1"}) {
+				t.Errorf("pushed branches = %v, %v, want [upgrade/v1.0.1]", pushed, err)
+			}
+			head, err := osexec.Command("git", "-C", f.BotRepo, "rev-parse", "upgrade/v1.0.1").Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tag, _ := f.UpstreamSha("v1.0.1"); strings.TrimSpace(string(head)) != tag {
+				t.Errorf("the branch is at %s, want v1.0.1 %s", head, tag)
+			}
+			branches, err := s.ListBotBranches("upgrade/")
+			if err != nil || !reflect.DeepEqual(branches, []string{"upgrade/v1.0.1"}) {
+				t.Errorf("ListBotBranches = %v, %v, want [upgrade/v1.0.1]", branches, err)
+			}
+			if err := s.DeleteBotBranch("upgrade/v1.0.1"); err != nil {
+				t.Fatalf("DeleteBotBranch: %v", err)
+			}
+			if pushed, err := f.BotBranches(); err != nil || len(pushed) != 0 {
+				t.Errorf("branches after the deletion = %v, %v, want none", pushed, err)
+			}
+
+			if err := s.ClearQuorumRepository(); err != nil {
+				t.Fatalf("ClearQuorumRepository: %v", err)
+			}
+			if _, err := os.Stat(cfg.QuorumRepoFolder); !os.IsNotExist(err) {
+				t.Errorf("the clone %s exists: %v", cfg.QuorumRepoFolder, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrMergeFailed - the merge of a geth tag failed for another reason than conflicts
+	ErrMergeFailed = errors.New("merge failed")
+	// ErrVersionNotFound - the geth version could not be found in the Quorum repository
+	ErrVersionNotFound = errors.New("geth version not found")
+	// ErrUnstableVersion - the geth version of the Quorum repository is a development version, not a release
+	ErrUnstableVersion = errors.New("unstable geth version")
+	// ErrMirrorLocked - the mirror of Quorum is used by another run
+	ErrMirrorLocked = errors.New("quorum mirror locked")
+)
+
+// CommandError - a git command that exited with an error, with its (redacted) arguments and stderr
+type CommandError struct {
+	Args   []string
+	Stderr string
+	Err    error
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("git %s: %v", strings.Join(e.Args, " "), e.Err)
+	if e.Stderr != "" {
+		msg += ": " + strings.TrimSpace(e.Stderr)
+	}
+	return msg
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
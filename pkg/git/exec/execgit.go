@@ -0,0 +1,310 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"upgradebot/config"
+	"upgradebot/pkg/git"
+	"upgradebot/pkg/redact"
+)
+
+// credentialHelper - git credential helper answering with the credentials passed in env vars by the bot,
+// so that the token is neither part of the remote URLs, nor of .git/config, nor of the logged commands
+const credentialHelper = `!f() { test "$1" = get && echo "username=${UPGRADEBOT_GIT_USERNAME}" && echo "password=${UPGRADEBOT_GIT_PASSWORD}"; }; f`
+
+// ExecGit - Git running the git binary, the only implementation detecting the merge conflicts
+type ExecGit struct {
+	config       *config.Config
+	redactor     *redact.Redactor
+	mirrorLocked bool
+}
+
+func NewGit(config *config.Config) git.Git {
+	return &ExecGit{config: config, redactor: redact.New(config.GithubUserToken)}
+}
+
+// CloneQuorumRepository - clone the repository of Quorum locally and add the go-ethereum remote as `geth`,
+// or check it out from the mirror if one is configured
+func (s *ExecGit) CloneQuorumRepository() error {
+	if s.config.QuorumMirrorFolder != "" {
+		return s.addQuorumWorktree()
+	}
+
+	if _, err := s.executeGitCommand("clone", s.config.QuorumGitRepo, s.config.QuorumRepoFolder); err != nil {
+		return fmt.Errorf("clone quorum: %w", err)
+	}
+
+	// add quorum bot fork
+	if _, err := s.executeGitCommandOnRepo("remote", "add", "quorumbot", s.config.QuorumBotGitRepo); err != nil {
+		return fmt.Errorf("add quorumbot remote: %w", err)
+	}
+
+	// load geth tags
+	if _, err := s.executeGitCommandOnRepo("remote", "add", "geth", s.config.GethGitRepo); err != nil {
+		return fmt.Errorf("add geth remote: %w", err)
+	}
+	if _, err := s.executeGitCommandOnRepo("fetch", "geth", "--tags"); err != nil {
+		return fmt.Errorf("fetch geth tags: %w", err)
+	}
+	return nil
+}
+
+// ClearQuorumRepository - delete the repository folder, keeping the mirror if one is configured
+func (s *ExecGit) ClearQuorumRepository() error {
+	if s.config.QuorumMirrorFolder != "" {
+		return s.removeQuorumWorktree()
+	}
+	return os.RemoveAll(s.config.QuorumRepoFolder)
+}
+
+// CreateBranchFromGethTag - create a branch from a geth tag and push the branch to the remote quorum
+func (s *ExecGit) CreateBranchFromGethTag(targetTag string, branchName string) error {
+	if _, err := s.executeGitCommandOnRepo("checkout", "tags/"+targetTag, "-b", branchName); err != nil {
+		return fmt.Errorf("create branch %s: %w", branchName, err)
+	}
+	if _, err := s.executeGitCommandOnRepo("push", "-u", "quorumbot", branchName); err != nil {
+		return fmt.Errorf("push branch %s: %w", branchName, err)
+	}
+	return nil
+}
+
+// ListBotBranches - list the branches of the quorumbot fork starting with a prefix
+func (s *ExecGit) ListBotBranches(prefix string) ([]string, error) {
+	output, err := s.executeGitCommand("ls-remote", "--heads", s.config.QuorumBotGitRepo, "refs/heads/"+prefix+"*")
+	if err != nil {
+		return nil, fmt.Errorf("list quorumbot branches: %w", err)
+	}
+
+	branches := make([]string, 0)
+	for _, line := range splitLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		branches = append(branches, strings.TrimPrefix(fields[1], "refs/heads/"))
+	}
+	return branches, nil
+}
+
+// DeleteBotBranch - delete a branch from the quorumbot fork
+func (s *ExecGit) DeleteBotBranch(branchName string) error {
+	if _, err := s.executeGitCommand("push", s.config.QuorumBotGitRepo, "--delete", branchName); err != nil {
+		return fmt.Errorf("delete branch %s: %w", branchName, err)
+	}
+	return nil
+}
+
+// GetVersion - Get the geth and Quorum versions of the Quorum version file
+func (s *ExecGit) GetVersion() (git.Version, error) {
+	out, err := ioutil.ReadFile(s.config.QuorumRepoFolder + s.config.QuorumVersionFilePath)
+	if err != nil {
+		return git.Version{}, fmt.Errorf("read %s: %w", s.config.QuorumVersionFilePath, err)
+	}
+	return git.ParseVersion(out, s.config.QuorumVersionFilePath)
+}
+
+// GetBaseGethTag - Get current version of go-ethereum merged into Quorum, from its version file
+func (s *ExecGit) GetBaseGethTag() (string, error) {
+	version, err := s.GetVersion()
+	if err != nil {
+		return "", err
+	}
+	return version.GethTag()
+}
+
+// GetMergedGethTag - Get the most recent geth tag merged into Quorum, ignoring the tags of Quorum itself
+func (s *ExecGit) GetMergedGethTag() (string, error) {
+	output, err := s.executeGitCommandOnRepo("ls-remote", "--tags", "--refs", "geth")
+	if err != nil {
+		return "", fmt.Errorf("list geth tags: %w", err)
+	}
+	gethTags := make(map[string]bool)
+	for _, line := range splitLines(output) {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			gethTags[strings.TrimPrefix(fields[1], "refs/tags/")] = true
+		}
+	}
+
+	output, err = s.executeGitCommandOnRepo("for-each-ref", "--merged=HEAD", "--sort=-creatordate", "--format=%(refname:short)", "refs/tags/")
+	if err != nil {
+		return "", fmt.Errorf("list merged tags: %w", err)
+	}
+	for _, tag := range splitLines(output) {
+		if gethTags[tag] {
+			return tag, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no geth tag is an ancestor of HEAD", git.ErrVersionNotFound)
+}
+
+// GetConflictsFilesAgainstGethTargetVersion - Get the list of filenames that will have conflicts between Quorum master and the target geth tag
+func (s *ExecGit) GetConflictsFilesAgainstGethTargetVersion(targetGethTag string) (files []string, err error) {
+	_, mergeErr := s.executeGitCommandOnRepo("merge", "--no-commit", "--no-ff", targetGethTag)
+	defer func() {
+		// nothing to abort when the target is already merged, as there is no MERGE_HEAD
+		if _, headErr := s.executeGitCommandOnRepo("rev-parse", "-q", "--verify", "MERGE_HEAD"); headErr != nil {
+			return
+		}
+		if _, abortErr := s.executeGitCommandOnRepo("merge", "--abort"); abortErr != nil && err == nil {
+			err = fmt.Errorf("abort merge of %s: %w", targetGethTag, abortErr)
+		}
+	}()
+
+	output, err := s.executeGitCommandOnRepo("diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, fmt.Errorf("list conflicts: %w", err)
+	}
+	files = splitLines(output)
+
+	// a merge with conflicts exits with an error, any other error means the merge could not be attempted
+	if mergeErr != nil && len(files) == 0 {
+		return nil, fmt.Errorf("%w: %s: %v", git.ErrMergeFailed, targetGethTag, mergeErr)
+	}
+	return files, nil
+}
+
+// GetChangedFilesAgainstGethBaseVersion - Get the list of filenames that were changed by quorum when comparing with the same geth tag currently merged into quorum.
+// Without rename detection, a file moved by quorum is listed under its geth name as well, as with GetChangedHunks
+func (s *ExecGit) GetChangedFilesAgainstGethBaseVersion(baseGethTag string) ([]string, error) {
+	output, err := s.executeGitCommandOnRepo("diff", "--name-only", "--no-renames", baseGethTag)
+	if err != nil {
+		return nil, fmt.Errorf("diff against %s: %w", baseGethTag, err)
+	}
+	return splitLines(output), nil
+}
+
+// GetChangedHunks - Get the hunks of each file changed between two revisions, without context lines nor rename detection
+// so that the lines of a file are numbered in the `from` revision, for the given paths only if any
+func (s *ExecGit) GetChangedHunks(from string, to string, paths ...string) (map[string][]git.Hunk, error) {
+	args := []string{"diff", "-U0", "--no-renames", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", from, to}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	output, err := s.executeGitCommandOnRepo(args...)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s against %s: %w", to, from, err)
+	}
+	hunks, err := git.ParseUnifiedDiffHunks(output)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s against %s: %w", to, from, err)
+	}
+	return hunks, nil
+}
+
+// GetFileContents - Get the contents of files at a revision with a single `git cat-file --batch`,
+// the files absent from the revision are reported missing by git and left out
+func (s *ExecGit) GetFileContents(revision string, paths []string) (map[string][]byte, error) {
+	contents := make(map[string][]byte)
+	if len(paths) == 0 {
+		return contents, nil
+	}
+	input := strings.Builder{}
+	for _, path := range paths {
+		fmt.Fprintf(&input, "%s:%s\n", revision, path)
+	}
+	arg := []string{"cat-file", "--batch"}
+	cmd := s.newGitCommand(arg...)
+	cmd.Dir = s.config.QuorumRepoFolder
+	cmd.Stdin = strings.NewReader(input.String())
+	output, err := s.run(cmd, arg)
+	if err != nil {
+		return nil, fmt.Errorf("read files at %s: %w", revision, err)
+	}
+
+	// each object is either `<input> missing` or `<sha> <type> <size>` followed by its content and a newline
+	reader := bufio.NewReader(bytes.NewReader(output))
+	for _, path := range paths {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("read files at %s: %s: %w", revision, path, err)
+		}
+		header = strings.TrimSuffix(header, "\n")
+		if strings.HasSuffix(header, " missing") || strings.HasSuffix(header, " ambiguous") {
+			continue
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("read files at %s: %s: unexpected header %q", revision, path, header)
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("read files at %s: %s: invalid size %q", revision, path, fields[2])
+		}
+		content := make([]byte, size+1)
+		if _, err := io.ReadFull(reader, content); err != nil {
+			return nil, fmt.Errorf("read files at %s: %s: %w", revision, path, err)
+		}
+		if fields[1] == "blob" {
+			contents[path] = content[:size]
+		}
+	}
+	return contents, nil
+}
+
+func (s *ExecGit) executeGitCommandOnRepo(arg ...string) ([]byte, error) {
+	cmd := s.newGitCommand(arg...)
+	cmd.Dir = s.config.QuorumRepoFolder
+	return s.run(cmd, arg)
+}
+
+func (s *ExecGit) executeGitCommand(arg ...string) ([]byte, error) {
+	cmd := s.newGitCommand(arg...)
+	return s.run(cmd, arg)
+}
+
+// newGitCommand - git command authenticated through the credential helper, replacing any helper configured on the host.
+// The bot identity is set as git refuses to even attempt a merge without identity.
+func (s *ExecGit) newGitCommand(arg ...string) *exec.Cmd {
+	args := append([]string{"-c", "credential.helper=", "-c", "credential.helper=" + credentialHelper}, arg...)
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"UPGRADEBOT_GIT_USERNAME="+s.config.GithubUsername,
+		"UPGRADEBOT_GIT_PASSWORD="+s.config.GithubUserToken,
+		"GIT_AUTHOR_NAME="+s.config.GithubUsername,
+		"GIT_AUTHOR_EMAIL="+s.config.GithubUsername+"@users.noreply.github.com",
+		"GIT_COMMITTER_NAME="+s.config.GithubUsername,
+		"GIT_COMMITTER_EMAIL="+s.config.GithubUsername+"@users.noreply.github.com",
+	)
+	return cmd
+}
+
+// run - run the git command, logging it and turning a failure into a CommandError, with the secrets redacted
+func (s *ExecGit) run(cmd *exec.Cmd, arg []string) ([]byte, error) {
+	redactedArgs := make([]string, len(arg))
+	for i, a := range arg {
+		redactedArgs[i] = s.redactor.String(a)
+	}
+	log.Println("git " + strings.Join(redactedArgs, " "))
+
+	output, err := cmd.Output()
+	if err != nil {
+		cmdErr := &git.CommandError{Args: redactedArgs, Err: err}
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			cmdErr.Stderr = s.redactor.String(string(exitErr.Stderr))
+		}
+		return output, cmdErr
+	}
+	return output, nil
+}
+
+// splitLines - split the output of a git command in lines, ignoring empty lines
+func splitLines(output []byte) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(string(output), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,146 @@
+package exec
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"upgradebot/config"
+	"upgradebot/pkg/git"
+	"upgradebot/pkg/git/gitfixture"
+)
+
+const testToken = "ghp_testtoken"
+
+// newTestFixture - the fork scenario of the git backends with the downstream files, and a config with credentials using it
+func newTestFixture(t *testing.T, downstreamFiles map[string]string) (*gitfixture.Fixture, *config.Config) {
+	t.Helper()
+	f := gitfixture.NewForkScenario(t, downstreamFiles)
+	cfg := config.Default()
+	cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", testToken
+	f.Configure(cfg)
+	return f, cfg
+}
+
+func TestCommandLineHasNoToken(t *testing.T) {
+	_, cfg := newTestFixture(t, map[string]string{"private/private.go": "package private\n"})
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	s := NewGit(cfg).(*ExecGit)
+	if err := s.CloneQuorumRepository(); err != nil {
+		t.Fatalf("CloneQuorumRepository: %v", err)
+	}
+	defer s.ClearQuorumRepository()
+
+	cmd := s.newGitCommand("clone", cfg.QuorumGitRepo, cfg.QuorumRepoFolder)
+	if args := strings.Join(cmd.Args, " "); strings.Contains(args, testToken) {
+		t.Errorf("git argv contains the token: %s", args)
+	}
+	for _, env := range cmd.Env {
+		if strings.Contains(env, testToken) && env != "UPGRADEBOT_GIT_PASSWORD="+testToken {
+			t.Errorf("git env contains the token outside of the credential helper variable: %s", env)
+		}
+	}
+
+	remotes, err := exec.Command("git", "-C", cfg.QuorumRepoFolder, "remote", "-v").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(remotes), testToken) {
+		t.Errorf("remote URLs contain the token: %s", remotes)
+	}
+	config, err := ioutil.ReadFile(filepath.Join(cfg.QuorumRepoFolder, ".git", "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(config), testToken) {
+		t.Errorf(".git/config contains the token: %s", config)
+	}
+	if strings.Contains(logs.String(), testToken) {
+		t.Errorf("logged git commands contain the token: %s", logs.String())
+	}
+}
+
+func TestCommandErrorHasNoToken(t *testing.T) {
+	cfg := config.Default()
+	cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", testToken
+	// a URL with the token embedded by the user, unreachable
+	cfg.QuorumBotGitRepo = "http://quorumbot:" + testToken + "@127.0.0.1:1/quorum.git"
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	_, err := NewGit(cfg).ListBotBranches("upgrade/")
+	if err == nil {
+		t.Fatal("ListBotBranches: expected an error")
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Errorf("error contains the token: %v", err)
+	}
+	if strings.Contains(logs.String(), testToken) {
+		t.Errorf("logged git commands contain the token: %s", logs.String())
+	}
+}
+
+func TestGetConflictsFilesAgainstGethTargetVersion(t *testing.T) {
+	tests := []struct {
+		name            string
+		downstreamFiles map[string]string
+		target          string
+		want            []string
+	}{
+		{
+			name:            "no conflict",
+			downstreamFiles: map[string]string{"private/private.go": "package private\n"},
+			target:          "v1.0.1",
+			want:            []string{},
+		},
+		{
+			name:            "same lines changed",
+			downstreamFiles: map[string]string{"core/blockchain.go": "package core\n\nfunc InsertChain() { private() }\n"},
+			target:          "v1.0.1",
+			want:            []string{"core/blockchain.go"},
+		},
+		{
+			name:            "already merged",
+			downstreamFiles: map[string]string{"core/blockchain.go": "package core\n\nfunc InsertChain() { private() }\n"},
+			target:          "v1.0.0",
+			want:            []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, cfg := newTestFixture(t, test.downstreamFiles)
+			s := NewGit(cfg)
+			if err := s.CloneQuorumRepository(); err != nil {
+				t.Fatalf("CloneQuorumRepository: %v", err)
+			}
+			defer s.ClearQuorumRepository()
+
+			files, err := s.(git.ConflictDetector).GetConflictsFilesAgainstGethTargetVersion(test.target)
+			if err != nil {
+				t.Fatalf("GetConflictsFilesAgainstGethTargetVersion: %v", err)
+			}
+			if !reflect.DeepEqual(files, test.want) {
+				t.Errorf("conflicts = %v, want %v", files, test.want)
+			}
+
+			// the merge is aborted
+			status, err := exec.Command("git", "-C", cfg.QuorumRepoFolder, "status", "--porcelain").Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(status) != 0 {
+				t.Errorf("the clone is not clean after the conflict detection: %s", status)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"upgradebot/pkg/git"
+)
+
+// mirror remotes, the quorumbot fork is only pushed to
+var mirrorRemotes = []string{"origin", "quorumbot", "geth"}
+
+// addQuorumWorktree - fetch the persistent mirror of Quorum (created on the first run) and check out the base branch
+// in a worktree of the run. The mirror is locked until ClearQuorumRepository.
+func (s *ExecGit) addQuorumWorktree() error {
+	if err := s.lockMirror(); err != nil {
+		return err
+	}
+
+	urls := map[string]string{
+		"origin":    s.config.QuorumGitRepo,
+		"quorumbot": s.config.QuorumBotGitRepo,
+		"geth":      s.config.GethGitRepo,
+	}
+	if _, err := os.Stat(s.config.QuorumMirrorFolder); os.IsNotExist(err) {
+		if _, err := s.executeGitCommand("init", "--bare", s.config.QuorumMirrorFolder); err != nil {
+			return fmt.Errorf("create quorum mirror: %w", err)
+		}
+		for _, remote := range mirrorRemotes {
+			if _, err := s.executeGitCommandOnMirror("remote", "add", remote, urls[remote]); err != nil {
+				return fmt.Errorf("add %s remote: %w", remote, err)
+			}
+		}
+	} else {
+		// the URLs may have changed in the config since the mirror was created
+		for _, remote := range mirrorRemotes {
+			if _, err := s.executeGitCommandOnMirror("remote", "set-url", remote, urls[remote]); err != nil {
+				return fmt.Errorf("update %s remote: %w", remote, err)
+			}
+		}
+	}
+
+	if _, err := s.executeGitCommandOnMirror("fetch", "origin", "--prune"); err != nil {
+		return fmt.Errorf("fetch quorum: %w", err)
+	}
+	if _, err := s.executeGitCommandOnMirror("fetch", "geth", "--tags"); err != nil {
+		return fmt.Errorf("fetch geth tags: %w", err)
+	}
+
+	worktree, err := filepath.Abs(s.config.QuorumRepoFolder)
+	if err != nil {
+		return err
+	}
+	// forget the worktrees of the runs that did not clean up
+	if _, err := s.executeGitCommandOnMirror("worktree", "prune"); err != nil {
+		return fmt.Errorf("prune worktrees: %w", err)
+	}
+	if _, err := s.executeGitCommandOnMirror("worktree", "add", "--detach", worktree, "origin/"+s.config.QuorumBaseBranch); err != nil {
+		return fmt.Errorf("add quorum worktree: %w", err)
+	}
+	return nil
+}
+
+// removeQuorumWorktree - delete the worktree of the run and the branches it created, then unlock the mirror
+func (s *ExecGit) removeQuorumWorktree() error {
+	if !s.mirrorLocked {
+		if err := s.lockMirror(); err != nil {
+			return err
+		}
+	}
+	defer s.unlockMirror()
+
+	if err := os.RemoveAll(s.config.QuorumRepoFolder); err != nil {
+		return err
+	}
+	if _, err := os.Stat(s.config.QuorumMirrorFolder); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := s.executeGitCommandOnMirror("worktree", "prune"); err != nil {
+		return fmt.Errorf("prune worktrees: %w", err)
+	}
+
+	// the fetched branches are remote-tracking, the local branches are the ones created by the runs
+	output, err := s.executeGitCommandOnMirror("for-each-ref", "--format=%(refname:short)", "refs/heads/")
+	if err != nil {
+		return fmt.Errorf("list mirror branches: %w", err)
+	}
+	for _, branch := range splitLines(output) {
+		if _, err := s.executeGitCommandOnMirror("branch", "-D", branch); err != nil {
+			return fmt.Errorf("delete branch %s: %w", branch, err)
+		}
+	}
+	return nil
+}
+
+// lockMirror - create the lock file of the mirror, failing if another run holds it.
+// The lock of a run that is no longer running on this host is stale and taken over.
+func (s *ExecGit) lockMirror() error {
+	lockPath := s.mirrorLockPath()
+	file, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) && removeStaleLock(lockPath) {
+		file, err = os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	}
+	if os.IsExist(err) {
+		return fmt.Errorf("%w: %s is held by a running process or by another host, delete it if no other run is in progress", git.ErrMirrorLocked, lockPath)
+	}
+	if err != nil {
+		return fmt.Errorf("lock quorum mirror: %w", err)
+	}
+	defer file.Close()
+	s.mirrorLocked = true
+
+	// owner of the lock, to detect a stale lock and for the humans investigating it
+	hostname, _ := os.Hostname()
+	_, err = fmt.Fprintf(file, "pid %d host %s since %s\n", os.Getpid(), hostname, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// removeStaleLock - delete the lock file if its owner is a process of this host that is not running anymore.
+// A lock of another host, or that cannot be read, is kept.
+func removeStaleLock(lockPath string) bool {
+	content, err := ioutil.ReadFile(lockPath)
+	if err != nil {
+		return false
+	}
+	var pid int
+	var host string
+	if _, err := fmt.Sscanf(string(content), "pid %d host %s", &pid, &host); err != nil {
+		return false
+	}
+	if hostname, _ := os.Hostname(); host != hostname || processRunning(pid) {
+		return false
+	}
+	log.Printf("Removing the stale lock of the Quorum mirror: %s\n", strings.TrimSpace(string(content)))
+	return os.Remove(lockPath) == nil
+}
+
+// processRunning - whether a process of this host is running, signal 0 only checks that it exists
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func (s *ExecGit) unlockMirror() {
+	if err := os.Remove(s.mirrorLockPath()); err != nil {
+		log.Printf("Failed to unlock the Quorum mirror: %v\n", err)
+	}
+	s.mirrorLocked = false
+}
+
+// mirrorLockPath - lock file next to the mirror folder, so that it can be taken before the mirror is created
+func (s *ExecGit) mirrorLockPath() string {
+	return filepath.Clean(s.config.QuorumMirrorFolder) + ".lock"
+}
+
+func (s *ExecGit) executeGitCommandOnMirror(arg ...string) ([]byte, error) {
+	cmd := s.newGitCommand(arg...)
+	cmd.Dir = s.config.QuorumMirrorFolder
+	return s.run(cmd, arg)
+}
@@ -0,0 +1,124 @@
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upgradebot/pkg/git"
+)
+
+func TestMirror(t *testing.T) {
+	f, cfg := newTestFixture(t, map[string]string{"private/private.go": "package private\n"})
+	cfg.QuorumMirrorFolder = filepath.Join(f.Dir, "mirror")
+	lockPath := cfg.QuorumMirrorFolder + ".lock"
+
+	first := NewGit(cfg)
+	if err := first.CloneQuorumRepository(); err != nil {
+		t.Fatalf("CloneQuorumRepository: %v", err)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Errorf("lock of the mirror: %v", err)
+	}
+
+	// a concurrent run, with its own worktree, cannot use the mirror
+	concurrentCfg := *cfg
+	concurrentCfg.QuorumRepoFolder = filepath.Join(f.Dir, "concurrent-clone")
+	if err := NewGit(&concurrentCfg).CloneQuorumRepository(); !errors.Is(err, git.ErrMirrorLocked) {
+		t.Errorf("concurrent CloneQuorumRepository = %v, want %v", err, git.ErrMirrorLocked)
+	}
+
+	if err := first.ClearQuorumRepository(); err != nil {
+		t.Fatalf("ClearQuorumRepository: %v", err)
+	}
+	for _, path := range []string{cfg.QuorumRepoFolder, lockPath} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s after the cleanup: %v, want it deleted", path, err)
+		}
+	}
+	worktrees, err := exec.Command("git", "-C", cfg.QuorumMirrorFolder, "worktree", "list", "--porcelain").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(worktrees), cfg.QuorumRepoFolder) {
+		t.Errorf("worktrees of the mirror after the cleanup:\n%s", worktrees)
+	}
+
+	// a later run reuses the mirror and fetches the new upstream tags
+	if err := f.UpstreamRelease("v1.0.2", map[string]string{"core/blockchain.go": "package core\n\nfunc InsertChain() { verify(); commit() }\n"}); err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	later := NewGit(cfg)
+	if err := later.CloneQuorumRepository(); err != nil {
+		t.Fatalf("later CloneQuorumRepository: %v", err)
+	}
+	defer later.ClearQuorumRepository()
+	if strings.Contains(logs.String(), "init --bare") {
+		t.Errorf("the mirror is created again:\n%s", logs.String())
+	}
+	tag, err := exec.Command("git", "-C", cfg.QuorumRepoFolder, "rev-parse", "v1.0.2^{commit}").Output()
+	if err != nil {
+		t.Fatalf("v1.0.2 is not fetched: %v", err)
+	}
+	if want, _ := f.UpstreamSha("v1.0.2"); strings.TrimSpace(string(tag)) != want {
+		t.Errorf("v1.0.2 = %s, want %s", tag, want)
+	}
+}
+
+func TestMirrorLockLeftBehind(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a process that has exited
+	exited := exec.Command("git", "--version")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		lock       string
+		wantLocked bool
+	}{
+		{name: "run killed on this host", lock: fmt.Sprintf("pid %d host %s since 2020-01-01T00:00:00Z\n", exited.Process.Pid, hostname)},
+		{name: "running process", lock: fmt.Sprintf("pid %d host %s since 2020-01-01T00:00:00Z\n", os.Getpid(), hostname), wantLocked: true},
+		{name: "other host", lock: fmt.Sprintf("pid %d host other-%s since 2020-01-01T00:00:00Z\n", exited.Process.Pid, hostname), wantLocked: true},
+		{name: "unknown owner", lock: "locked\n", wantLocked: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, cfg := newTestFixture(t, map[string]string{"private/private.go": "package private\n"})
+			cfg.QuorumMirrorFolder = filepath.Join(f.Dir, "mirror")
+			if err := ioutil.WriteFile(cfg.QuorumMirrorFolder+".lock", []byte(test.lock), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			s := NewGit(cfg)
+			err := s.CloneQuorumRepository()
+			if test.wantLocked {
+				if !errors.Is(err, git.ErrMirrorLocked) {
+					t.Errorf("CloneQuorumRepository = %v, want %v", err, git.ErrMirrorLocked)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CloneQuorumRepository: %v", err)
+			}
+			defer s.ClearQuorumRepository()
+			lock, err := ioutil.ReadFile(cfg.QuorumMirrorFolder + ".lock")
+			if err != nil || !strings.HasPrefix(string(lock), fmt.Sprintf("pid %d ", os.Getpid())) {
+				t.Errorf("lock = %q, %v, want the lock of this run", lock, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package gitfixture
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"upgradebot/config"
+)
+
+// versionFilePath - path of the geth version file, as in config.Default
+const versionFilePath = "params/version.go"
+
+// fixtureDate - date of the first command, each command is a minute later
+var fixtureDate = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var tagMatcher = regexp.MustCompile(`^v(\d+)\.(\d+)\.(\d+)(?:-(\w+))?$`)
+
+// Fixture - local bare repositories simulating go-ethereum (the upstream with its release tags),
+// Quorum (a downstream fork of a geth tag with divergent commits) and the quorumbot fork of Quorum.
+// Commits have a fixed author and date, so that the repositories are deterministic.
+type Fixture struct {
+	Dir string
+
+	UpstreamRepo   string
+	DownstreamRepo string
+	BotRepo        string
+
+	upstreamWork   string
+	downstreamWork string
+	commandCount   int
+}
+
+// New - create the bare repositories and their work trees in dir, with an initial upstream commit on master
+func New(dir string) (*Fixture, error) {
+	f := &Fixture{
+		Dir:            dir,
+		UpstreamRepo:   filepath.Join(dir, "upstream.git"),
+		DownstreamRepo: filepath.Join(dir, "downstream.git"),
+		BotRepo:        filepath.Join(dir, "bot.git"),
+		upstreamWork:   filepath.Join(dir, "upstream-work"),
+		downstreamWork: filepath.Join(dir, "downstream-work"),
+	}
+
+	for _, repo := range []string{f.UpstreamRepo, f.DownstreamRepo, f.BotRepo} {
+		if err := f.git(dir, "init", "--bare", repo); err != nil {
+			return nil, err
+		}
+	}
+	if err := f.git(dir, "clone", f.UpstreamRepo, f.upstreamWork); err != nil {
+		return nil, err
+	}
+	if err := f.commit(f.upstreamWork, "initial commit", map[string]string{"README.md": "go-ethereum\n"}); err != nil {
+		return nil, err
+	}
+	if err := f.git(f.upstreamWork, "push", "origin", "master"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Release - upstream release of a fork, with the files committed for it
+type Release struct {
+	Tag   string
+	Files map[string]string
+}
+
+// NewFork - fixture in a temporary directory of the test, with the upstream releases in order and a downstream fork
+// of the first one committing downstreamFiles. The test fails if the repositories cannot be built.
+func NewFork(t testing.TB, releases []Release, downstreamFiles map[string]string) *Fixture {
+	t.Helper()
+	f, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, release := range releases {
+		if err := f.UpstreamRelease(release.Tag, release.Files); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.ForkDownstream(releases[0].Tag); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.DownstreamCommit("quorum changes", downstreamFiles); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// NewForkScenario - fork of the tests of the git backends: upstream with the releases v1.0.0 and v1.0.1 changing
+// the line 3 of core/blockchain.go, and Quorum forked from v1.0.0 with a commit of downstreamFiles
+func NewForkScenario(t testing.TB, downstreamFiles map[string]string) *Fixture {
+	t.Helper()
+	return NewFork(t, []Release{
+		{Tag: "v1.0.0", Files: map[string]string{"core/blockchain.go": "package core\n\nfunc InsertChain() {}\n"}},
+		{Tag: "v1.0.1", Files: map[string]string{"core/blockchain.go": "package core\n\nfunc InsertChain() { verify() }\n"}},
+	}, downstreamFiles)
+}
+
+// Configure - point the git repositories of the config to the fixture, the Quorum clone is created in Dir
+func (f *Fixture) Configure(cfg *config.Config) {
+	cfg.GethGitRepo = f.UpstreamRepo
+	cfg.QuorumGitRepo = f.DownstreamRepo
+	cfg.QuorumBotGitRepo = f.BotRepo
+	cfg.QuorumRepoFolder = filepath.Join(f.Dir, "quorum-clone")
+	cfg.QuorumVersionFilePath = "/" + versionFilePath
+}
+
+// UpstreamRelease - commit the files and the params/version.go of the tag (e.g. v1.9.8 or v1.10.0-unstable)
+// on the upstream master, tag the commit and push it
+func (f *Fixture) UpstreamRelease(tag string, files map[string]string) error {
+	version, err := VersionFile(tag)
+	if err != nil {
+		return err
+	}
+	all := map[string]string{versionFilePath: version}
+	for name, content := range files {
+		all[name] = content
+	}
+
+	if err := f.commit(f.upstreamWork, "release "+tag, all); err != nil {
+		return err
+	}
+	if err := f.git(f.upstreamWork, "tag", tag); err != nil {
+		return err
+	}
+	return f.git(f.upstreamWork, "push", "origin", "master", tag)
+}
+
+// UpstreamCommit - commit files on the upstream master, without a release
+func (f *Fixture) UpstreamCommit(message string, files map[string]string) error {
+	if err := f.commit(f.upstreamWork, message, files); err != nil {
+		return err
+	}
+	return f.git(f.upstreamWork, "push", "origin", "master")
+}
+
+// ForkDownstream - create the downstream master from an upstream tag, only master is pushed to the downstream repository
+func (f *Fixture) ForkDownstream(tag string) error {
+	if err := f.git(f.Dir, "clone", f.UpstreamRepo, f.downstreamWork); err != nil {
+		return err
+	}
+	if err := f.git(f.downstreamWork, "checkout", "-B", "master", tag); err != nil {
+		return err
+	}
+	if err := f.git(f.downstreamWork, "remote", "rename", "origin", "upstream"); err != nil {
+		return err
+	}
+	if err := f.git(f.downstreamWork, "remote", "add", "origin", f.DownstreamRepo); err != nil {
+		return err
+	}
+	return f.git(f.downstreamWork, "push", "origin", "master")
+}
+
+// DownstreamCommit - commit files on the downstream master, diverging from upstream
+func (f *Fixture) DownstreamCommit(message string, files map[string]string) error {
+	if err := f.commit(f.downstreamWork, message, files); err != nil {
+		return err
+	}
+	return f.git(f.downstreamWork, "push", "origin", "master")
+}
+
+// DownstreamRename - move a file on the downstream master without changing it, as a rename detected by git
+func (f *Fixture) DownstreamRename(from string, to string) error {
+	if err := f.git(f.downstreamWork, "mv", from, to); err != nil {
+		return err
+	}
+	if err := f.git(f.downstreamWork, "commit", "-m", "rename "+from); err != nil {
+		return err
+	}
+	return f.git(f.downstreamWork, "push", "origin", "master")
+}
+
+// MergeUpstream - merge an upstream tag into the downstream master, as done by a completed upgrade.
+// Conflicts are resolved with the downstream version.
+func (f *Fixture) MergeUpstream(tag string) error {
+	if err := f.git(f.downstreamWork, "fetch", "--no-tags", "upstream", "tag", tag); err != nil {
+		return err
+	}
+	if err := f.git(f.downstreamWork, "merge", "--no-ff", "-X", "ours", "-m", "merge "+tag, tag); err != nil {
+		return err
+	}
+	// the version file always follows the merged geth version
+	version, err := VersionFile(tag)
+	if err != nil {
+		return err
+	}
+	if err := f.commit(f.downstreamWork, "version "+tag, map[string]string{versionFilePath: version}); err != nil {
+		return err
+	}
+	return f.git(f.downstreamWork, "push", "origin", "master")
+}
+
+// BotBranch - push a branch at an upstream tag to the bot repository, as done by an upgrade
+func (f *Fixture) BotBranch(branch string, tag string) error {
+	return f.git(f.upstreamWork, "push", f.BotRepo, tag+"^{commit}:refs/heads/"+branch)
+}
+
+// BotBranches - branches pushed to the bot repository
+func (f *Fixture) BotBranches() ([]string, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/heads/")
+	cmd.Dir = f.BotRepo
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("list bot branches: %w", err)
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// UpstreamSha - SHA of an upstream revision, e.g. a tag or `master`
+func (f *Fixture) UpstreamSha(revision string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", revision+"^{commit}")
+	cmd.Dir = f.UpstreamRepo
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", revision, err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// VersionFile - content of params/version.go for a tag, in the format of go-ethereum
+func VersionFile(tag string) (string, error) {
+	match := tagMatcher.FindStringSubmatch(tag)
+	if match == nil {
+		return "", fmt.Errorf("invalid tag %q, expected vMAJOR.MINOR.PATCH[-META]", tag)
+	}
+	meta := match[4]
+	if meta == "" {
+		meta = "stable"
+	}
+	return fmt.Sprintf(`package params
+
+const (
+	VersionMajor = %s        // Major version component of the current release
+	VersionMinor = %s        // Minor version component of the current release
+	VersionPatch = %s        // Patch version component of the current release
+	VersionMeta  = %q // Version metadata to append to the version string
+)
+`, match[1], match[2], match[3], meta), nil
+}
+
+// commit - write the files in the work tree and commit them
+func (f *Fixture) commit(work string, message string, files map[string]string) error {
+	for name, content := range files {
+		path := filepath.Join(work, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	if err := f.git(work, "add", "-A"); err != nil {
+		return err
+	}
+	return f.git(work, "commit", "--allow-empty", "-m", message)
+}
+
+// git - run a git command with a fixed identity and a commit date increasing with each command
+func (f *Fixture) git(dir string, arg ...string) error {
+	f.commandCount++
+	date := fixtureDate.Add(time.Duration(f.commandCount) * time.Minute).Format(time.RFC3339)
+
+	cmd := exec.Command("git", append([]string{"-c", "init.defaultBranch=master", "-c", "commit.gpgsign=false", "-c", "tag.gpgsign=false"}, arg...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=fixture", "GIT_AUTHOR_EMAIL=fixture@example.com", "GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME=fixture", "GIT_COMMITTER_EMAIL=fixture@example.com", "GIT_COMMITTER_DATE="+date,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", strings.Join(arg, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
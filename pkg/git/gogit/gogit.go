@@ -0,0 +1,468 @@
+package gogit
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"upgradebot/config"
+	"upgradebot/pkg/git"
+	"upgradebot/pkg/redact"
+)
+
+// GoGit - Git in pure Go, without the git binary for the http(s) and ssh remotes. It cannot merge, so it does not detect the merge conflicts.
+// go-git still runs git-upload-pack and git-receive-pack for the local repositories.
+type GoGit struct {
+	config     *config.Config
+	redactor   *redact.Redactor
+	inMemory   bool
+	repository *gogit.Repository
+}
+
+// NewGit - Git cloning Quorum in the configured folder
+func NewGit(config *config.Config) git.Git {
+	return &GoGit{config: config, redactor: redact.New(config.GithubUserToken)}
+}
+
+// NewInMemoryGit - Git cloning Quorum in memory, the configured folder is not used
+func NewInMemoryGit(config *config.Config) git.Git {
+	return &GoGit{config: config, redactor: redact.New(config.GithubUserToken), inMemory: true}
+}
+
+// CloneQuorumRepository - clone the repository of Quorum and add the go-ethereum remote as `geth`
+func (s *GoGit) CloneQuorumRepository() error {
+	s.logf("go-git clone %s %s", s.config.QuorumGitRepo, s.config.QuorumRepoFolder)
+	options := &gogit.CloneOptions{URL: s.config.QuorumGitRepo, Auth: s.auth(s.config.QuorumGitRepo)}
+	var repository *gogit.Repository
+	var err error
+	if s.inMemory {
+		repository, err = gogit.Clone(memory.NewStorage(), memfs.New(), options)
+	} else {
+		repository, err = gogit.PlainClone(s.config.QuorumRepoFolder, false, options)
+	}
+	if err != nil {
+		return fmt.Errorf("clone quorum: %w", err)
+	}
+	s.repository = repository
+
+	// add quorum bot fork
+	if _, err := repository.CreateRemote(&gitconfig.RemoteConfig{Name: "quorumbot", URLs: []string{s.config.QuorumBotGitRepo}}); err != nil {
+		return fmt.Errorf("add quorumbot remote: %w", err)
+	}
+
+	// load geth tags
+	if _, err := repository.CreateRemote(&gitconfig.RemoteConfig{Name: "geth", URLs: []string{s.config.GethGitRepo}}); err != nil {
+		return fmt.Errorf("add geth remote: %w", err)
+	}
+	s.logf("go-git fetch geth --tags")
+	err = repository.Fetch(&gogit.FetchOptions{
+		RemoteName: "geth",
+		RefSpecs:   []gitconfig.RefSpec{"+refs/tags/*:refs/tags/*"},
+		Tags:       gogit.AllTags,
+		Auth:       s.auth(s.config.GethGitRepo),
+	})
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return fmt.Errorf("fetch geth tags: %w", err)
+	}
+	return nil
+}
+
+// ClearQuorumRepository - delete the repository folder, or forget the in-memory repository
+func (s *GoGit) ClearQuorumRepository() error {
+	s.repository = nil
+	if s.inMemory {
+		return nil
+	}
+	return os.RemoveAll(s.config.QuorumRepoFolder)
+}
+
+// CreateBranchFromGethTag - create a branch from a geth tag and push the branch to the remote quorum
+func (s *GoGit) CreateBranchFromGethTag(targetTag string, branchName string) error {
+	hash, err := s.resolveTag(targetTag)
+	if err != nil {
+		return fmt.Errorf("create branch %s: %w", branchName, err)
+	}
+	worktree, err := s.repository.Worktree()
+	if err != nil {
+		return fmt.Errorf("create branch %s: %w", branchName, err)
+	}
+	s.logf("go-git checkout tags/%s -b %s", targetTag, branchName)
+	branch := plumbing.NewBranchReferenceName(branchName)
+	if err := worktree.Checkout(&gogit.CheckoutOptions{Hash: hash, Branch: branch, Create: true}); err != nil {
+		return fmt.Errorf("create branch %s: %w", branchName, err)
+	}
+
+	s.logf("go-git push quorumbot %s", branchName)
+	err = s.repository.Push(&gogit.PushOptions{
+		RemoteName: "quorumbot",
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(branch + ":" + branch)},
+		Auth:       s.auth(s.config.QuorumBotGitRepo),
+	})
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return fmt.Errorf("push branch %s: %w", branchName, err)
+	}
+	return nil
+}
+
+// ListBotBranches - list the branches of the quorumbot fork starting with a prefix
+func (s *GoGit) ListBotBranches(prefix string) ([]string, error) {
+	s.logf("go-git ls-remote --heads %s", s.config.QuorumBotGitRepo)
+	refs, err := s.botRemote().List(&gogit.ListOptions{Auth: s.auth(s.config.QuorumBotGitRepo)})
+	if err != nil && !errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return nil, fmt.Errorf("list quorumbot branches: %w", err)
+	}
+
+	branches := make([]string, 0)
+	for _, ref := range refs {
+		if ref.Name().IsBranch() && strings.HasPrefix(ref.Name().Short(), prefix) {
+			branches = append(branches, ref.Name().Short())
+		}
+	}
+	sort.Strings(branches)
+	return branches, nil
+}
+
+// DeleteBotBranch - delete a branch from the quorumbot fork
+func (s *GoGit) DeleteBotBranch(branchName string) error {
+	s.logf("go-git push %s --delete %s", s.config.QuorumBotGitRepo, branchName)
+	err := s.botRemote().Push(&gogit.PushOptions{
+		RemoteName: "quorumbot",
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(":" + plumbing.NewBranchReferenceName(branchName))},
+		Auth:       s.auth(s.config.QuorumBotGitRepo),
+	})
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return fmt.Errorf("delete branch %s: %w", branchName, err)
+	}
+	return nil
+}
+
+// GetVersion - Get the geth and Quorum versions of the Quorum version file
+func (s *GoGit) GetVersion() (git.Version, error) {
+	worktree, err := s.repository.Worktree()
+	if err != nil {
+		return git.Version{}, err
+	}
+	file, err := worktree.Filesystem.Open(strings.TrimPrefix(s.config.QuorumVersionFilePath, "/"))
+	if err != nil {
+		return git.Version{}, fmt.Errorf("read %s: %w", s.config.QuorumVersionFilePath, err)
+	}
+	defer file.Close()
+	out, err := ioutil.ReadAll(file)
+	if err != nil {
+		return git.Version{}, fmt.Errorf("read %s: %w", s.config.QuorumVersionFilePath, err)
+	}
+	return git.ParseVersion(out, s.config.QuorumVersionFilePath)
+}
+
+// GetBaseGethTag - Get current version of go-ethereum merged into Quorum, from its version file
+func (s *GoGit) GetBaseGethTag() (string, error) {
+	version, err := s.GetVersion()
+	if err != nil {
+		return "", err
+	}
+	return version.GethTag()
+}
+
+// GetMergedGethTag - Get the most recent geth tag merged into Quorum, ignoring the tags of Quorum itself
+func (s *GoGit) GetMergedGethTag() (string, error) {
+	remote, err := s.repository.Remote("geth")
+	if err != nil {
+		return "", err
+	}
+	refs, err := remote.List(&gogit.ListOptions{Auth: s.auth(s.config.GethGitRepo)})
+	if err != nil {
+		return "", fmt.Errorf("list geth tags: %w", err)
+	}
+	gethTags := make(map[plumbing.Hash]string)
+	for _, ref := range refs {
+		if !ref.Name().IsTag() {
+			continue
+		}
+		hash, err := s.resolveTag(ref.Name().Short())
+		if errors.Is(err, gogit.ErrTagNotFound) {
+			// released after the clone, so it cannot be merged into the cloned Quorum
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		gethTags[hash] = ref.Name().Short()
+	}
+
+	head, err := s.repository.Head()
+	if err != nil {
+		return "", err
+	}
+	commits, err := s.repository.Log(&gogit.LogOptions{From: head.Hash(), Order: gogit.LogOrderCommitterTime})
+	if err != nil {
+		return "", err
+	}
+	defer commits.Close()
+	tag := ""
+	err = commits.ForEach(func(commit *object.Commit) error {
+		if gethTag, ok := gethTags[commit.Hash]; ok {
+			tag = gethTag
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("walk quorum history: %w", err)
+	}
+	if tag == "" {
+		return "", fmt.Errorf("%w: no geth tag is an ancestor of HEAD", git.ErrVersionNotFound)
+	}
+	return tag, nil
+}
+
+// GetChangedFilesAgainstGethBaseVersion - Get the list of filenames that were changed by quorum when comparing with the same geth tag currently merged into quorum
+func (s *GoGit) GetChangedFilesAgainstGethBaseVersion(baseGethTag string) ([]string, error) {
+	baseHash, err := s.resolveTag(baseGethTag)
+	if err != nil {
+		return nil, fmt.Errorf("diff against %s: %w", baseGethTag, err)
+	}
+	head, err := s.repository.Head()
+	if err != nil {
+		return nil, fmt.Errorf("diff against %s: %w", baseGethTag, err)
+	}
+	baseTree, err := s.tree(baseHash)
+	if err != nil {
+		return nil, fmt.Errorf("diff against %s: %w", baseGethTag, err)
+	}
+	headTree, err := s.tree(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("diff against %s: %w", baseGethTag, err)
+	}
+	changes, err := object.DiffTree(baseTree, headTree)
+	if err != nil {
+		return nil, fmt.Errorf("diff against %s: %w", baseGethTag, err)
+	}
+
+	files := make([]string, 0, len(changes))
+	for _, change := range changes {
+		// a deleted file only has a name before the change
+		name := change.To.Name
+		if name == "" {
+			name = change.From.Name
+		}
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// GetChangedHunks - Get the hunks of each file changed between two revisions, numbered in the `from` revision,
+// for the given paths only if any
+func (s *GoGit) GetChangedHunks(from string, to string, paths ...string) (map[string][]git.Hunk, error) {
+	fromTree, err := s.revisionTree(from)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s against %s: %w", to, from, err)
+	}
+	toTree, err := s.revisionTree(to)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s against %s: %w", to, from, err)
+	}
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s against %s: %w", to, from, err)
+	}
+	if len(paths) > 0 {
+		changes = filterChanges(changes, paths)
+	}
+	patch, err := changes.Patch()
+	if err != nil {
+		return nil, fmt.Errorf("diff %s against %s: %w", to, from, err)
+	}
+
+	hunks := make(map[string][]git.Hunk)
+	for _, filePatch := range patch.FilePatches() {
+		if filePatch.IsBinary() {
+			continue
+		}
+		fromFile, toFile := filePatch.Files()
+		name := ""
+		if fromFile != nil {
+			name = fromFile.Path()
+		} else {
+			name = toFile.Path()
+		}
+		hunks[name] = chunksToHunks(filePatch.Chunks())
+	}
+	return hunks, nil
+}
+
+// filterChanges - changes of the given paths, before or after the change
+func filterChanges(changes object.Changes, paths []string) object.Changes {
+	filter := make(map[string]bool)
+	for _, path := range paths {
+		filter[path] = true
+	}
+	filtered := make(object.Changes, 0, len(changes))
+	for _, change := range changes {
+		if filter[change.From.Name] || filter[change.To.Name] {
+			filtered = append(filtered, change)
+		}
+	}
+	return filtered
+}
+
+// GetFileContents - Get the contents of files at a revision, the files absent from the revision are left out
+func (s *GoGit) GetFileContents(revision string, paths []string) (map[string][]byte, error) {
+	tree, err := s.revisionTree(revision)
+	if err != nil {
+		return nil, fmt.Errorf("read files at %s: %w", revision, err)
+	}
+	contents := make(map[string][]byte)
+	for _, path := range paths {
+		file, err := tree.File(path)
+		if err == object.ErrFileNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read files at %s: %s: %w", revision, path, err)
+		}
+		content, err := file.Contents()
+		if err != nil {
+			return nil, fmt.Errorf("read files at %s: %s: %w", revision, path, err)
+		}
+		contents[path] = []byte(content)
+	}
+	return contents, nil
+}
+
+// chunksToHunks - hunks of the chunks of a file patch, a deletion followed by an addition being a single hunk as in git
+func chunksToHunks(chunks []diff.Chunk) []git.Hunk {
+	hunks := make([]git.Hunk, 0)
+	var current *git.Hunk
+	line, newLine := 1, 1
+	for _, chunk := range chunks {
+		count := countLines(chunk.Content())
+		switch chunk.Type() {
+		case diff.Equal:
+			if current != nil {
+				hunks = append(hunks, *current)
+				current = nil
+			}
+			line += count
+			newLine += count
+		case diff.Delete:
+			if current == nil {
+				current = &git.Hunk{Start: line, NewStart: newLine - 1}
+			}
+			// the hunk may start with the addition
+			if current.Count == 0 {
+				current.Start = line
+			}
+			current.Count += count
+			line += count
+		case diff.Add:
+			if current == nil {
+				current = &git.Hunk{Start: line - 1, NewStart: newLine - 1}
+			}
+			// the hunk may start with the deletion
+			if current.NewCount == 0 {
+				current.NewStart = newLine
+			}
+			current.NewCount += count
+			newLine += count
+		}
+	}
+	if current != nil {
+		hunks = append(hunks, *current)
+	}
+	return hunks
+}
+
+func countLines(content string) int {
+	count := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		count++
+	}
+	return count
+}
+
+// revisionTree - tree of HEAD, of a tag or of another revision such as `<sha>^`
+func (s *GoGit) revisionTree(revision string) (*object.Tree, error) {
+	if revision == "HEAD" {
+		head, err := s.repository.Head()
+		if err != nil {
+			return nil, err
+		}
+		return s.tree(head.Hash())
+	}
+	hash, err := s.resolveTag(revision)
+	if errors.Is(err, gogit.ErrTagNotFound) {
+		// a commit, e.g. `<sha>^`
+		resolved, resolveErr := s.repository.ResolveRevision(plumbing.Revision(revision))
+		if resolveErr != nil {
+			return nil, fmt.Errorf("revision %s: %w", revision, resolveErr)
+		}
+		hash, err = *resolved, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.tree(hash)
+}
+
+// resolveTag - commit of a tag, peeling annotated tags
+func (s *GoGit) resolveTag(tag string) (plumbing.Hash, error) {
+	ref, err := s.repository.Tag(tag)
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("tag %s: %w", tag, err)
+	}
+	tagObject, err := s.repository.TagObject(ref.Hash())
+	if err == plumbing.ErrObjectNotFound {
+		return ref.Hash(), nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("tag %s: %w", tag, err)
+	}
+	commit, err := tagObject.Commit()
+	if err != nil {
+		return plumbing.ZeroHash, fmt.Errorf("tag %s: %w", tag, err)
+	}
+	return commit.Hash, nil
+}
+
+func (s *GoGit) tree(hash plumbing.Hash) (*object.Tree, error) {
+	commit, err := s.repository.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// botRemote - remote of the quorumbot fork, usable without a clone
+func (s *GoGit) botRemote() *gogit.Remote {
+	return gogit.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{Name: "quorumbot", URLs: []string{s.config.QuorumBotGitRepo}})
+}
+
+// logf - log a go-git operation, with the secrets and the URL credentials redacted as by the exec backend
+func (s *GoGit) logf(format string, arg ...interface{}) {
+	log.Println(s.redactor.String(fmt.Sprintf(format, arg...)))
+}
+
+// auth - credentials of the bot for the GitHub repositories, none for the local ones
+func (s *GoGit) auth(url string) transport.AuthMethod {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil || (endpoint.Protocol != "http" && endpoint.Protocol != "https") {
+		return nil
+	}
+	return &githttp.BasicAuth{Username: s.config.GithubUsername, Password: s.config.GithubUserToken}
+}
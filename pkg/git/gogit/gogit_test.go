@@ -0,0 +1,107 @@
+package gogit
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+
+	"upgradebot/config"
+	"upgradebot/pkg/git"
+)
+
+type testChunk struct {
+	content   string
+	operation diff.Operation
+}
+
+func (c testChunk) Content() string {
+	return c.content
+}
+
+func (c testChunk) Type() diff.Operation {
+	return c.operation
+}
+
+func TestChunksToHunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []diff.Chunk
+		want   []git.Hunk
+	}{
+		{
+			name: "modified line",
+			chunks: []diff.Chunk{
+				testChunk{"1\n2\n", diff.Equal}, testChunk{"3\n", diff.Delete}, testChunk{"x\n", diff.Add}, testChunk{"4\n5\n", diff.Equal},
+			},
+			want: []git.Hunk{{Start: 3, Count: 1, NewStart: 3, NewCount: 1}},
+		},
+		{
+			name:   "deleted lines",
+			chunks: []diff.Chunk{testChunk{"1\n", diff.Equal}, testChunk{"2\n3\n", diff.Delete}, testChunk{"4\n", diff.Equal}},
+			want:   []git.Hunk{{Start: 2, Count: 2, NewStart: 1}},
+		},
+		{
+			name:   "added lines",
+			chunks: []diff.Chunk{testChunk{"1\n", diff.Equal}, testChunk{"a\nb\n", diff.Add}, testChunk{"2\n", diff.Equal}},
+			want:   []git.Hunk{{Start: 1, NewStart: 2, NewCount: 2}},
+		},
+		{
+			name:   "addition followed by a deletion",
+			chunks: []diff.Chunk{testChunk{"1\n", diff.Equal}, testChunk{"x\n", diff.Add}, testChunk{"2\n", diff.Delete}},
+			want:   []git.Hunk{{Start: 2, Count: 1, NewStart: 2, NewCount: 1}},
+		},
+		{
+			name: "first line deleted and a line added at the end without newline",
+			chunks: []diff.Chunk{
+				testChunk{"1\n", diff.Delete}, testChunk{"2\n3\n", diff.Equal}, testChunk{"y", diff.Add},
+			},
+			want: []git.Hunk{{Start: 1, Count: 1}, {Start: 3, NewStart: 3, NewCount: 1}},
+		},
+		{
+			name:   "new file",
+			chunks: []diff.Chunk{testChunk{"package core\n\nfunc A() {}\n", diff.Add}},
+			want:   []git.Hunk{{NewStart: 1, NewCount: 3}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if hunks := chunksToHunks(test.chunks); !reflect.DeepEqual(hunks, test.want) {
+				t.Errorf("chunksToHunks = %v, want %v", hunks, test.want)
+			}
+		})
+	}
+}
+
+func TestLogsHaveNoToken(t *testing.T) {
+	const token = "ghp_testtoken"
+	cfg := config.Default()
+	cfg.GithubUsername, cfg.GithubUserToken = "quorumbot", token
+	// URLs with the token embedded by the user, unreachable
+	cfg.QuorumGitRepo = "http://quorumbot:" + token + "@127.0.0.1:1/quorum.git"
+	cfg.QuorumBotGitRepo = "http://quorumbot:" + token + "@127.0.0.1:1/quorum.git"
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	s := NewInMemoryGit(cfg)
+	if err := s.CloneQuorumRepository(); err == nil {
+		t.Error("CloneQuorumRepository: expected an error")
+	}
+	if _, err := s.ListBotBranches("upgrade/"); err == nil {
+		t.Error("ListBotBranches: expected an error")
+	}
+	if err := s.DeleteBotBranch("upgrade/v1.0.1"); err == nil {
+		t.Error("DeleteBotBranch: expected an error")
+	}
+	if !strings.Contains(logs.String(), "go-git ls-remote") {
+		t.Errorf("logs = %s, want the go-git operations", logs.String())
+	}
+	if strings.Contains(logs.String(), token) {
+		t.Errorf("logged go-git operations contain the token: %s", logs.String())
+	}
+}
@@ -0,0 +1,108 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Hunk - lines of a file changed by a diff, numbered in the file before the change (from 1).
+// A pure addition has no line: Count is 0 and the lines are added after the line Start.
+// NewStart and NewCount are the lines after the change, a pure deletion being after the line NewStart.
+type Hunk struct {
+	Start    int
+	Count    int
+	NewStart int
+	NewCount int
+}
+
+// End - last line of the hunk, Start - 1 for a pure addition
+func (h Hunk) End() int {
+	return h.Start + h.Count - 1
+}
+
+var hunkHeaderMatcher = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParseUnifiedDiffHunks - hunks of each file of a `git diff -U0 --no-renames` output, keyed by the path of the file.
+// Binary files have no hunk. The `---` and `+++` lines are file headers only between a `diff --git` line and the first hunk,
+// and the lines of a hunk are counted from its header, so that changed lines starting with `-- ` or `++ ` are not headers.
+func ParseUnifiedDiffHunks(diff []byte) (map[string][]Hunk, error) {
+	hunks := make(map[string][]Hunk)
+	oldPath, file := "", ""
+	inHeader := false
+	// lines of the current hunk still to read, on the old and new sides
+	oldLines, newLines := 0, 0
+	for _, line := range strings.Split(string(diff), "\n") {
+		if oldLines > 0 || newLines > 0 {
+			switch {
+			case strings.HasPrefix(line, "-"):
+				oldLines--
+			case strings.HasPrefix(line, "+"):
+				newLines--
+			case strings.HasPrefix(line, " "):
+				oldLines--
+				newLines--
+			case strings.HasPrefix(line, "\\"):
+				// "\ No newline at end of file"
+			default:
+				return nil, fmt.Errorf("malformed hunk of %s: unexpected line %q", file, line)
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			oldPath, file = "", ""
+			inHeader = true
+		case inHeader && strings.HasPrefix(line, "--- "):
+			path, err := diffHeaderPath(strings.TrimPrefix(line, "--- "), "a/")
+			if err != nil {
+				return nil, err
+			}
+			oldPath = path
+		case inHeader && strings.HasPrefix(line, "+++ "):
+			// an added file has no old path
+			file = oldPath
+			if file == "/dev/null" {
+				path, err := diffHeaderPath(strings.TrimPrefix(line, "+++ "), "b/")
+				if err != nil {
+					return nil, err
+				}
+				file = path
+			}
+		case strings.HasPrefix(line, "@@ "):
+			match := hunkHeaderMatcher.FindStringSubmatch(line)
+			if match == nil || file == "" {
+				return nil, fmt.Errorf("malformed hunk header %q", line)
+			}
+			inHeader = false
+			hunk := Hunk{Count: 1}
+			hunk.Start, _ = strconv.Atoi(match[1])
+			if match[2] != "" {
+				hunk.Count, _ = strconv.Atoi(match[2])
+			}
+			hunk.NewStart, _ = strconv.Atoi(match[3])
+			hunk.NewCount = 1
+			if match[4] != "" {
+				hunk.NewCount, _ = strconv.Atoi(match[4])
+			}
+			oldLines, newLines = hunk.Count, hunk.NewCount
+			hunks[file] = append(hunks[file], hunk)
+		}
+	}
+	return hunks, nil
+}
+
+// diffHeaderPath - path of a `---` or `+++` file header without its prefix. Git quotes the paths with special characters
+// in C style (e.g. "a/na\303\257ve.go"), and ends the paths with a space with a tab
+func diffHeaderPath(header string, prefix string) (string, error) {
+	path := strings.TrimSuffix(header, "\t")
+	if strings.HasPrefix(path, `"`) {
+		unquoted, err := strconv.Unquote(path)
+		if err != nil {
+			return "", fmt.Errorf("malformed file header %q: %w", header, err)
+		}
+		path = unquoted
+	}
+	return strings.TrimPrefix(path, prefix), nil
+}
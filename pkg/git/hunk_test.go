@@ -0,0 +1,149 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUnifiedDiffHunks(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want map[string][]Hunk
+	}{
+		{
+			name: "modified, added and deleted files",
+			diff: `diff --git a/core/state.go b/core/state.go
+index 1111111..2222222 100644
+--- a/core/state.go
++++ b/core/state.go
+@@ -3 +3 @@ func a() {
+-	a := 1
++	a := 2
+@@ -10,2 +9,0 @@ func b() {
+-	b := 1
+-	b++
+@@ -20,0 +18,2 @@ func c() {
++	c := 1
++	c++
+diff --git a/core/new.go b/core/new.go
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/core/new.go
+@@ -0,0 +1,2 @@
++package core
++
+diff --git a/core/old.go b/core/old.go
+deleted file mode 100644
+index 4444444..0000000
+--- a/core/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package core
+`,
+			want: map[string][]Hunk{
+				"core/state.go": {{Start: 3, Count: 1, NewStart: 3, NewCount: 1}, {Start: 10, Count: 2, NewStart: 9}, {Start: 20, NewStart: 18, NewCount: 2}},
+				"core/new.go":   {{NewStart: 1, NewCount: 2}},
+				"core/old.go":   {{Start: 1, Count: 1}},
+			},
+		},
+		{
+			name: "changed lines looking like file headers",
+			diff: `diff --git a/README.md b/README.md
+index 1111111..2222222 100644
+--- a/README.md
++++ b/README.md
+@@ -4,2 +4,2 @@ Title
+--- a/list
+-+++ b/list
++--- a/other
+++++ b/other
+@@ -8 +8 @@ Title
+-x
++y
+`,
+			want: map[string][]Hunk{
+				"README.md": {{Start: 4, Count: 2, NewStart: 4, NewCount: 2}, {Start: 8, Count: 1, NewStart: 8, NewCount: 1}},
+			},
+		},
+		{
+			name: "missing newline at end of file",
+			diff: `diff --git a/a.txt b/a.txt
+index 1111111..2222222 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1 @@
+-a
+\ No newline at end of file
++b
+\ No newline at end of file
+`,
+			want: map[string][]Hunk{
+				"a.txt": {{Start: 1, Count: 1, NewStart: 1, NewCount: 1}},
+			},
+		},
+		{
+			name: "quoted paths",
+			diff: `diff --git "a/core/na\303\257ve.go" "b/core/na\303\257ve.go"
+index 1111111..2222222 100644
+--- "a/core/na\303\257ve.go"
++++ "b/core/na\303\257ve.go"
+@@ -2 +2 @@
+-a
++b
+diff --git "a/core/tab\t\"q\".go" "b/core/tab\t\"q\".go"
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ "b/core/tab\t\"q\".go"
+@@ -0,0 +1 @@
++package core
+diff --git a/core/with space.go b/core/with space.go
+index 1111111..2222222 100644
+--- a/core/with space.go	
++++ b/core/with space.go	
+@@ -5 +5 @@
+-a
++b
+`,
+			want: map[string][]Hunk{
+				"core/naïve.go":      {{Start: 2, Count: 1, NewStart: 2, NewCount: 1}},
+				"core/tab\t\"q\".go": {{NewStart: 1, NewCount: 1}},
+				"core/with space.go": {{Start: 5, Count: 1, NewStart: 5, NewCount: 1}},
+			},
+		},
+		{
+			name: "binary file",
+			diff: `diff --git a/logo.png b/logo.png
+index 1111111..2222222 100644
+Binary files a/logo.png and b/logo.png differ
+`,
+			want: map[string][]Hunk{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hunks, err := ParseUnifiedDiffHunks([]byte(test.diff))
+			if err != nil {
+				t.Fatalf("ParseUnifiedDiffHunks: %v", err)
+			}
+			if !reflect.DeepEqual(hunks, test.want) {
+				t.Errorf("ParseUnifiedDiffHunks = %v, want %v", hunks, test.want)
+			}
+		})
+	}
+}
+
+func TestParseUnifiedDiffHunksMalformed(t *testing.T) {
+	for _, diff := range []string{
+		"@@ -1 +1 @@\n-a\n+b\n",
+		"diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -x +1 @@\n",
+		"diff --git a/a.txt b/a.txt\n--- a/a.txt\n+++ b/a.txt\n@@ -1,2 +1 @@\n-a\ndiff --git a/b.txt b/b.txt\n",
+		"diff --git \"a/a.txt\" \"b/a.txt\"\n--- \"a/a.txt\n+++ \"b/a.txt\"\n@@ -1 +1 @@\n-a\n+b\n",
+	} {
+		if _, err := ParseUnifiedDiffHunks([]byte(diff)); err == nil {
+			t.Errorf("ParseUnifiedDiffHunks(%q): expected an error", diff)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package git
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// versionMetaStable - meta of the version file of a geth release, the development versions in between are `unstable`
+const versionMetaStable = "stable"
+
+// Version - constants of the Quorum version file (params/version.go): the version of geth it is based on and its own version
+//
+//	VersionMajor = 1        // Major version component of the current release
+//	VersionMinor = 9        // Minor version component of the current release
+//	VersionPatch = 8        // Patch version component of the current release
+//	VersionMeta  = "stable" // Version metadata to append to the version string
+//
+//	QuorumVersionMajor = 22
+//	QuorumVersionMinor = 7
+//	QuorumVersionPatch = 4
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+	Meta  string
+
+	// HasQuorumVersion - whether the file has the Quorum constants, upstream geth has none
+	HasQuorumVersion bool
+	QuorumMajor      int
+	QuorumMinor      int
+	QuorumPatch      int
+	QuorumMeta       string
+}
+
+// GethTag - tag of the geth release of the version, an unstable version is a development version between two releases
+func (v Version) GethTag() (string, error) {
+	if v.Meta != "" && v.Meta != versionMetaStable {
+		return "", fmt.Errorf("%w: %s is not a release", ErrUnstableVersion, v.GethVersion())
+	}
+	return fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch), nil
+}
+
+// GethVersion - geth version with its meta, e.g. 1.10.4-unstable
+func (v Version) GethVersion() string {
+	return withMeta(fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch), v.Meta)
+}
+
+// QuorumVersion - Quorum version with its meta if any, e.g. 22.7.4
+func (v Version) QuorumVersion() string {
+	return withMeta(fmt.Sprintf("%d.%d.%d", v.QuorumMajor, v.QuorumMinor, v.QuorumPatch), v.QuorumMeta)
+}
+
+func withMeta(version string, meta string) string {
+	if meta == "" {
+		return version
+	}
+	return version + "-" + meta
+}
+
+// ParseVersion - parse the constants of the version file at path, whatever their formatting and order
+func ParseVersion(content []byte, path string) (Version, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), path, content, 0)
+	if err != nil {
+		return Version{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	constants := make(map[string]*ast.BasicLit)
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, name := range valueSpec.Names {
+				if i >= len(valueSpec.Values) {
+					continue
+				}
+				if lit, ok := valueSpec.Values[i].(*ast.BasicLit); ok {
+					constants[name.Name] = lit
+				}
+			}
+		}
+	}
+
+	version := Version{}
+	ints := []intConstant{
+		{"VersionMajor", &version.Major},
+		{"VersionMinor", &version.Minor},
+		{"VersionPatch", &version.Patch},
+	}
+	quorumInts := []intConstant{
+		{"QuorumVersionMajor", &version.QuorumMajor},
+		{"QuorumVersionMinor", &version.QuorumMinor},
+		{"QuorumVersionPatch", &version.QuorumPatch},
+	}
+	for _, constant := range ints {
+		name := constant.name
+		found, err := parseIntConstant(constants, name, constant.field)
+		if err != nil {
+			return Version{}, fmt.Errorf("%s inside %s: %w", name, path, err)
+		}
+		if !found {
+			return Version{}, fmt.Errorf("%w: missing %s inside %s", ErrVersionNotFound, name, path)
+		}
+	}
+	quorumFound := 0
+	for _, constant := range quorumInts {
+		name := constant.name
+		found, err := parseIntConstant(constants, name, constant.field)
+		if err != nil {
+			return Version{}, fmt.Errorf("%s inside %s: %w", name, path, err)
+		}
+		if found {
+			quorumFound++
+		}
+	}
+	version.HasQuorumVersion = quorumFound == len(quorumInts)
+
+	if version.Meta, err = parseStringConstant(constants, "VersionMeta"); err != nil {
+		return Version{}, fmt.Errorf("VersionMeta inside %s: %w", path, err)
+	}
+	if version.QuorumMeta, err = parseStringConstant(constants, "QuorumVersionMeta"); err != nil {
+		return Version{}, fmt.Errorf("QuorumVersionMeta inside %s: %w", path, err)
+	}
+	return version, nil
+}
+
+// intConstant - integer constant of the version file and the field of Version it is parsed into
+type intConstant struct {
+	name  string
+	field *int
+}
+
+func parseIntConstant(constants map[string]*ast.BasicLit, name string, field *int) (bool, error) {
+	lit, ok := constants[name]
+	if !ok {
+		return false, nil
+	}
+	if lit.Kind != token.INT {
+		return false, fmt.Errorf("expected an integer, got %s", lit.Value)
+	}
+	value, err := strconv.ParseInt(lit.Value, 0, 0)
+	if err != nil {
+		return false, err
+	}
+	*field = int(value)
+	return true, nil
+}
+
+// parseStringConstant - value of a string constant, empty if it is missing
+func parseStringConstant(constants map[string]*ast.BasicLit, name string) (string, error) {
+	lit, ok := constants[name]
+	if !ok {
+		return "", nil
+	}
+	if lit.Kind != token.STRING {
+		return "", fmt.Errorf("expected a string, got %s", lit.Value)
+	}
+	return strconv.Unquote(lit.Value)
+}
@@ -0,0 +1,155 @@
+package git
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		want        Version
+		wantTag     string
+		wantTagErr  error
+		wantVersion string
+	}{
+		{
+			name: "upstream geth",
+			content: `package params
+
+const (
+	VersionMajor = 1          // Major version component of the current release
+	VersionMinor = 10         // Minor version component of the current release
+	VersionPatch = 17         // Patch version component of the current release
+	VersionMeta  = "stable"   // Version metadata to append to the version string
+)
+`,
+			want:        Version{Major: 1, Minor: 10, Patch: 17, Meta: "stable"},
+			wantTag:     "v1.10.17",
+			wantVersion: "1.10.17-stable",
+		},
+		{
+			name: "quorum, in any order and formatting",
+			content: `package params
+
+const QuorumVersionMajor = 22
+const (
+	VersionMeta = "stable"
+	VersionPatch, VersionMinor, VersionMajor = 8, 9, 1
+)
+
+const (
+	QuorumVersionMinor = 7
+	QuorumVersionPatch = 0x4
+	QuorumVersionMeta  = ` + "`rc1`" + `
+)
+`,
+			want: Version{Major: 1, Minor: 9, Patch: 8, Meta: "stable",
+				HasQuorumVersion: true, QuorumMajor: 22, QuorumMinor: 7, QuorumPatch: 4, QuorumMeta: "rc1"},
+			wantTag:     "v1.9.8",
+			wantVersion: "1.9.8-stable",
+		},
+		{
+			name: "unstable development version",
+			content: `package params
+
+const (
+	VersionMajor = 1
+	VersionMinor = 10
+	VersionPatch = 18
+	VersionMeta  = "unstable"
+)
+`,
+			want:        Version{Major: 1, Minor: 10, Patch: 18, Meta: "unstable"},
+			wantTagErr:  ErrUnstableVersion,
+			wantVersion: "1.10.18-unstable",
+		},
+		{
+			name: "no meta and partial quorum version",
+			content: `package params
+
+const (
+	VersionMajor = 1
+	VersionMinor = 8
+	VersionPatch = 18
+
+	QuorumVersionMajor = 2
+)
+`,
+			want:        Version{Major: 1, Minor: 8, Patch: 18, QuorumMajor: 2},
+			wantTag:     "v1.8.18",
+			wantVersion: "1.8.18",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, err := ParseVersion([]byte(test.content), "params/version.go")
+			if err != nil {
+				t.Fatalf("ParseVersion: %v", err)
+			}
+			if !reflect.DeepEqual(version, test.want) {
+				t.Errorf("ParseVersion = %+v, want %+v", version, test.want)
+			}
+			tag, err := version.GethTag()
+			if !errors.Is(err, test.wantTagErr) {
+				t.Errorf("GethTag error = %v, want %v", err, test.wantTagErr)
+			}
+			if tag != test.wantTag {
+				t.Errorf("GethTag = %q, want %q", tag, test.wantTag)
+			}
+			if got := version.GethVersion(); got != test.wantVersion {
+				t.Errorf("GethVersion = %q, want %q", got, test.wantVersion)
+			}
+		})
+	}
+}
+
+func TestParseVersionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr error
+	}{
+		{
+			name:    "missing patch",
+			content: "package params\n\nconst (\n\tVersionMajor = 1\n\tVersionMinor = 10\n)\n",
+			wantErr: ErrVersionNotFound,
+		},
+		{
+			name:    "string major",
+			content: "package params\n\nconst (\n\tVersionMajor = \"1\"\n\tVersionMinor = 10\n\tVersionPatch = 1\n)\n",
+		},
+		{
+			name:    "integer meta",
+			content: "package params\n\nconst (\n\tVersionMajor = 1\n\tVersionMinor = 10\n\tVersionPatch = 1\n\tVersionMeta = 1\n)\n",
+		},
+		{
+			name:    "not go",
+			content: "VersionMajor = 1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseVersion([]byte(test.content), "params/version.go")
+			if err == nil {
+				t.Fatal("ParseVersion: expected an error")
+			}
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Errorf("ParseVersion = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuorumVersion(t *testing.T) {
+	version := Version{QuorumMajor: 22, QuorumMinor: 7, QuorumPatch: 4}
+	if got := version.QuorumVersion(); got != "22.7.4" {
+		t.Errorf("QuorumVersion = %q, want 22.7.4", got)
+	}
+	version.QuorumMeta = "rc1"
+	if got := version.QuorumVersion(); got != "22.7.4-rc1" {
+		t.Errorf("QuorumVersion = %q, want 22.7.4-rc1", got)
+	}
+}
@@ -1,7 +1,17 @@
 package github
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Commit struct {
-	Sha string
+	Sha    string
+	Commit CommitDetails `json:"commit"`
+}
+
+type CommitDetails struct {
+	Message string `json:"message"`
 }
 
 type File struct {
@@ -23,6 +33,25 @@ type PullRequestData struct {
 	Body     string `json:"body"`
 	Comments int    `json:"comments"`
 	ClosedAt string `json:"closed_at"`
+	// MergeCommitSha - commit merging the PR into the base branch, the squashed commit for a squash merge
+	MergeCommitSha string `json:"merge_commit_sha"`
+
+	Head   PullRequestHead `json:"head"`
+	User   User            `json:"user"`
+	Labels []Label         `json:"labels"`
+}
+
+type User struct {
+	Login string `json:"login"`
+}
+
+type Label struct {
+	Name string `json:"name"`
+}
+
+type PullRequestHead struct {
+	Ref   string `json:"ref"`
+	Label string `json:"label"`
 }
 
 type PullRequest struct {
@@ -35,10 +64,25 @@ type CommitChanges struct {
 	Files   []File
 }
 
+// MergeCommitSha - commit merging a PR, found from the message GitHub gives to squash merges (`Title (#123)`)
+// and merge commits (`Merge pull request #123 from ...`), empty if no commit has such a message
+func (c CommitChanges) MergeCommitSha(number int) string {
+	squashSuffix := fmt.Sprintf("(#%d)", number)
+	mergePrefix := fmt.Sprintf("Merge pull request #%d ", number)
+	for _, commit := range c.Commits {
+		subject := strings.SplitN(commit.Commit.Message, "\n", 2)[0]
+		if strings.HasSuffix(strings.TrimSpace(subject), squashSuffix) || strings.HasPrefix(subject, mergePrefix) {
+			return commit.Sha
+		}
+	}
+	return ""
+}
+
 type ReleaseData struct {
 	Name        string
 	Body        string
 	Prerelease  bool
+	Draft       bool
 	Tag         string `json:"tag_name"`
 	PublishedAt string `json:"published_at"`
 }
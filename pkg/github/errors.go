@@ -0,0 +1,73 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNotFound - the requested GitHub resource (release, tag, PR...) does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited - the GitHub API rate limit has been exceeded
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUnknownRelease - the tag is not a go-ethereum release
+	ErrUnknownRelease = errors.New("unknown release")
+)
+
+// APIError - non-2xx response of the GitHub API, with the message and errors returned by GitHub
+type APIError struct {
+	Method      string
+	URL         string
+	StatusCode  int
+	RateLimited bool
+
+	Message          string           `json:"message"`
+	Errors           []APIErrorDetail `json:"errors"`
+	DocumentationURL string           `json:"documentation_url"`
+}
+
+// APIErrorDetail - detail of a validation error, e.g. `{"resource": "PullRequest", "code": "custom", "message": "A pull request already exists"}`
+type APIErrorDetail struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	builder := strings.Builder{}
+	fmt.Fprintf(&builder, "%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		fmt.Fprintf(&builder, ": %s", e.Message)
+	}
+	for _, detail := range e.Errors {
+		builder.WriteString(" [")
+		builder.WriteString(detail.String())
+		builder.WriteString("]")
+	}
+	return builder.String()
+}
+
+// Is - an APIError matches ErrNotFound on 404 and ErrRateLimited when rate-limited
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.RateLimited
+	default:
+		return false
+	}
+}
+
+func (d APIErrorDetail) String() string {
+	parts := make([]string, 0, 4)
+	for _, part := range []string{d.Resource, d.Field, d.Code, d.Message} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " ")
+}
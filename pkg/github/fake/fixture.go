@@ -0,0 +1,133 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"upgradebot/pkg/github"
+)
+
+// Fixture - in-memory state of the fake GitHub, repositories are keyed by `owner/name`
+type Fixture struct {
+	Repositories map[string]*Repository `json:"repositories"`
+}
+
+// Repository - releases, tag comparisons and PRs of a fake repository
+type Repository struct {
+	// Releases - ordered from the latest to the oldest, as returned by GitHub
+	Releases []github.ReleaseData `json:"releases"`
+	// Compares - result of the comparison of two tags, keyed by `base...target`
+	Compares     map[string]github.CommitChanges `json:"compares"`
+	PullRequests []*PullRequest                  `json:"pullRequests"`
+	// Labels - labels added to the issues and PRs, keyed by number
+	Labels map[int][]string `json:"labels"`
+	// ExistingLabels - labels defined in the repository, the GraphQL API can only add these while the REST API creates the missing ones
+	ExistingLabels []string `json:"existingLabels"`
+}
+
+// PullRequest - PR of a fake repository
+type PullRequest struct {
+	Data  github.PullRequestData `json:"data"`
+	Files []github.File          `json:"files"`
+	// State - `open` or `closed`
+	State  string `json:"state"`
+	Merged bool   `json:"merged"`
+	Draft  bool   `json:"draft"`
+	Base   string `json:"base"`
+	// Commits - SHAs of the commits merged by the PR, used to search the PRs by commit
+	Commits []string `json:"commits"`
+}
+
+// LoadFixture - load a fixture from a JSON file
+func LoadFixture(path string) (*Fixture, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read fixture: %w", err)
+	}
+	fixture := &Fixture{}
+	if err := json.Unmarshal(content, fixture); err != nil {
+		return nil, fmt.Errorf("parse fixture %s: %w", path, err)
+	}
+	return fixture, nil
+}
+
+// NewFixture - empty fixture
+func NewFixture() *Fixture {
+	return &Fixture{Repositories: make(map[string]*Repository)}
+}
+
+// Repository - the repository named `owner/name`, created if it does not exist
+func (f *Fixture) Repository(name string) *Repository {
+	if f.Repositories == nil {
+		f.Repositories = make(map[string]*Repository)
+	}
+	repo, ok := f.Repositories[name]
+	if !ok {
+		repo = &Repository{}
+		f.Repositories[name] = repo
+	}
+	if repo.Compares == nil {
+		repo.Compares = make(map[string]github.CommitChanges)
+	}
+	if repo.Labels == nil {
+		repo.Labels = make(map[int][]string)
+	}
+	return repo
+}
+
+// AddMergedPullRequest - add a merged PR with its files and the commits it merged
+func (r *Repository) AddMergedPullRequest(data github.PullRequestData, files []github.File, commits ...string) *PullRequest {
+	pr := &PullRequest{Data: data, Files: files, State: "closed", Merged: true, Commits: commits}
+	r.PullRequests = append(r.PullRequests, pr)
+	return pr
+}
+
+func (r *Repository) pullRequest(number int) *PullRequest {
+	for _, pr := range r.PullRequests {
+		if pr.Data.Number == number {
+			return pr
+		}
+	}
+	return nil
+}
+
+// createPullRequest - open a PR from the head `owner:branch`, nil and the validation message if one is already open for the head
+func (r *Repository) createPullRequest(repoName string, request github.CreatePullRequest) (*PullRequest, string) {
+	for _, pr := range r.PullRequests {
+		if pr.State == "open" && pr.Data.Head.Label == request.Head {
+			return nil, "A pull request already exists for " + request.Head + "."
+		}
+	}
+
+	number := r.nextPullRequestNumber()
+	ref := request.Head
+	if i := strings.Index(ref, ":"); i >= 0 {
+		ref = ref[i+1:]
+	}
+	pr := &PullRequest{
+		Data: github.PullRequestData{
+			Number:  number,
+			HtmlUrl: fmt.Sprintf("https://github.com/%s/pull/%d", repoName, number),
+			Title:   request.Title,
+			Body:    request.Body,
+			Head:    github.PullRequestHead{Ref: ref, Label: request.Head},
+		},
+		State: "open",
+		Draft: request.Draft,
+		Base:  request.Base,
+	}
+	r.PullRequests = append(r.PullRequests, pr)
+	return pr, ""
+}
+
+func (r *Repository) nextPullRequestNumber() int {
+	number := 1
+	for _, pr := range r.PullRequests {
+		if pr.Data.Number >= number {
+			number = pr.Data.Number + 1
+		}
+	}
+	return number
+}
@@ -0,0 +1,440 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"upgradebot/pkg/github"
+)
+
+// connectionPageSize - the bot fetches all the GraphQL connections by pages of 100 nodes
+const connectionPageSize = 100
+
+var pullRequestAliasMatcher = regexp.MustCompile(`(\w+): pullRequest\(number: (\d+)\)`)
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLError struct {
+	Type    string `json:"type,omitempty"`
+	Message string `json:"message"`
+}
+
+type graphQLPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type releaseNode struct {
+	Name         string `json:"name"`
+	TagName      string `json:"tagName"`
+	Description  string `json:"description"`
+	IsPrerelease bool   `json:"isPrerelease"`
+	IsDraft      bool   `json:"isDraft"`
+	PublishedAt  string `json:"publishedAt"`
+}
+
+type loginNode struct {
+	Login string `json:"login"`
+}
+
+type nameNode struct {
+	Name string `json:"name"`
+}
+
+type pullRequestNode struct {
+	Number      int    `json:"number"`
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Body        string `json:"body"`
+	ClosedAt    string `json:"closedAt"`
+	Merged      bool   `json:"merged"`
+	MergeCommit *struct {
+		Oid string `json:"oid"`
+	} `json:"mergeCommit"`
+	Comments struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"comments"`
+	Author *loginNode `json:"author"`
+	Labels struct {
+		Nodes []nameNode `json:"nodes"`
+	} `json:"labels"`
+	HeadRefName         string     `json:"headRefName"`
+	HeadRepositoryOwner *loginNode `json:"headRepositoryOwner"`
+	BaseRepository      struct {
+		NameWithOwner string `json:"nameWithOwner"`
+	} `json:"baseRepository"`
+}
+
+type fileNode struct {
+	Path       string `json:"path"`
+	Additions  int    `json:"additions"`
+	Deletions  int    `json:"deletions"`
+	ChangeType string `json:"changeType"`
+}
+
+type labelNode struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Color       string `json:"color"`
+	IsDefault   bool   `json:"isDefault"`
+	URL         string `json:"url"`
+}
+
+// graphQL - fake of the GitHub GraphQL endpoint answering the queries of the bot (pkg/github/graphql), recognized from
+// their fields: releases, release, compare, PR files (batched with aliases or paginated), open PRs, repository id,
+// labels, and the createPullRequest and addLabelsToLabelable mutations.
+// Node ids are `R_<owner/name>` for repositories, `PR_<owner/name>#<number>` for PRs and `LA_<name>` for labels.
+func (s *Server) graphQL(w http.ResponseWriter, r *http.Request) {
+	request := graphQLRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	query := request.Query
+	variables := request.Variables
+
+	var (
+		data interface{}
+		err  *graphQLError
+	)
+	switch {
+	case strings.Contains(query, "addLabelsToLabelable("):
+		data, err = s.addLabelsToLabelable(variables)
+	case strings.Contains(query, "createPullRequest("):
+		data, err = s.createPullRequestMutation(variables)
+	case strings.Contains(query, "releases("):
+		data, err = s.queryRepository(variables, func(repo *Repository) (interface{}, *graphQLError) {
+			return releasesConnection(repo, variables), nil
+		})
+	case strings.Contains(query, "release(tagName"):
+		data, err = s.queryRepository(variables, func(repo *Repository) (interface{}, *graphQLError) {
+			return map[string]interface{}{"release": findRelease(repo, stringVariable(variables, "tag"))}, nil
+		})
+	case strings.Contains(query, "compare(headRef"):
+		data, err = s.queryRepository(variables, func(repo *Repository) (interface{}, *graphQLError) {
+			return s.compareRef(repo, variables)
+		})
+	case strings.Contains(query, "pullRequests(states"):
+		data, err = s.queryRepository(variables, func(repo *Repository) (interface{}, *graphQLError) {
+			return openPullRequestsConnection(repo, stringVariable(variables, "name"), variables), nil
+		})
+	case strings.Contains(query, "labels(first: 100, query"):
+		data, err = s.queryRepository(variables, func(repo *Repository) (interface{}, *graphQLError) {
+			return repositoryLabels(repo, variables), nil
+		})
+	case strings.Contains(query, "pullRequest(number: $number)"):
+		data, err = s.queryRepository(variables, func(repo *Repository) (interface{}, *graphQLError) {
+			return pullRequestFiles(repo, variables)
+		})
+	case pullRequestAliasMatcher.MatchString(query):
+		data, err = s.queryRepository(variables, func(repo *Repository) (interface{}, *graphQLError) {
+			return aliasedPullRequestsFiles(repo, query)
+		})
+	case strings.Contains(query, "{ id }"):
+		data, err = s.queryRepository(variables, func(repo *Repository) (interface{}, *graphQLError) {
+			return map[string]interface{}{"id": "R_" + repositoryName(variables)}, nil
+		})
+	default:
+		err = &graphQLError{Message: "unsupported query"}
+	}
+
+	if err != nil {
+		writeJson(w, http.StatusOK, map[string]interface{}{"data": nil, "errors": []graphQLError{*err}})
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+// queryRepository - answer the `repository(owner: $owner, name: $name)` field with the result of fn
+func (s *Server) queryRepository(variables map[string]interface{}, fn func(repo *Repository) (interface{}, *graphQLError)) (interface{}, *graphQLError) {
+	name := repositoryName(variables)
+	if _, ok := s.fixture.Repositories[name]; !ok {
+		return nil, repositoryNotFound(name)
+	}
+	repository, err := fn(s.fixture.Repository(name))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"repository": repository}, nil
+}
+
+func releasesConnection(repo *Repository, variables map[string]interface{}) interface{} {
+	start, end, pageInfo := connectionPage(len(repo.Releases), variables)
+	nodes := make([]releaseNode, 0, end-start)
+	for _, release := range repo.Releases[start:end] {
+		nodes = append(nodes, toReleaseNode(release))
+	}
+	return map[string]interface{}{"releases": map[string]interface{}{"nodes": nodes, "pageInfo": pageInfo}}
+}
+
+func findRelease(repo *Repository, tag string) *releaseNode {
+	for _, release := range repo.Releases {
+		if release.Tag == tag {
+			node := toReleaseNode(release)
+			return &node
+		}
+	}
+	return nil
+}
+
+// compareRef - commits of the comparison of two tags with their associated PRs, the ref is null if the comparison is unknown
+func (s *Server) compareRef(repo *Repository, variables map[string]interface{}) (interface{}, *graphQLError) {
+	base := strings.TrimPrefix(stringVariable(variables, "base"), "refs/tags/")
+	head := strings.TrimPrefix(stringVariable(variables, "head"), "refs/tags/")
+	changes, ok := repo.Compares[base+"..."+head]
+	if !ok {
+		return map[string]interface{}{"ref": nil}, nil
+	}
+
+	type commitNode struct {
+		Oid                    string `json:"oid"`
+		AssociatedPullRequests struct {
+			Nodes []pullRequestNode `json:"nodes"`
+		} `json:"associatedPullRequests"`
+	}
+	start, end, pageInfo := connectionPage(len(changes.Commits), variables)
+	nodes := make([]commitNode, 0, end-start)
+	for _, commit := range changes.Commits[start:end] {
+		node := commitNode{Oid: commit.Sha}
+		node.AssociatedPullRequests.Nodes = make([]pullRequestNode, 0)
+		for _, pr := range repo.PullRequests {
+			if mergedAnyCommit(pr, []string{commit.Sha}) {
+				node.AssociatedPullRequests.Nodes = append(node.AssociatedPullRequests.Nodes, toPullRequestNode(pr, repositoryName(variables)))
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	return map[string]interface{}{
+		"ref": map[string]interface{}{
+			"compare": map[string]interface{}{
+				"commits": map[string]interface{}{"nodes": nodes, "pageInfo": pageInfo},
+			},
+		},
+	}, nil
+}
+
+func openPullRequestsConnection(repo *Repository, repoName string, variables map[string]interface{}) interface{} {
+	open := make([]*PullRequest, 0)
+	for _, pr := range repo.PullRequests {
+		if pr.State == "open" {
+			open = append(open, pr)
+		}
+	}
+	start, end, pageInfo := connectionPage(len(open), variables)
+	nodes := make([]pullRequestNode, 0, end-start)
+	for _, pr := range open[start:end] {
+		nodes = append(nodes, toPullRequestNode(pr, repoName))
+	}
+	return map[string]interface{}{"pullRequests": map[string]interface{}{"nodes": nodes, "pageInfo": pageInfo}}
+}
+
+// pullRequestFiles - page of the files of the PR $number
+func pullRequestFiles(repo *Repository, variables map[string]interface{}) (interface{}, *graphQLError) {
+	number := intVariable(variables, "number")
+	pr := repo.pullRequest(number)
+	if pr == nil {
+		return nil, pullRequestNotFound(number)
+	}
+	return map[string]interface{}{"pullRequest": map[string]interface{}{"files": filesConnection(pr, variables)}}, nil
+}
+
+// aliasedPullRequestsFiles - first page of the files of the PRs queried with aliases, `pr0: pullRequest(number: 123)`
+func aliasedPullRequestsFiles(repo *Repository, query string) (interface{}, *graphQLError) {
+	result := make(map[string]interface{})
+	for _, match := range pullRequestAliasMatcher.FindAllStringSubmatch(query, -1) {
+		number, _ := strconv.Atoi(match[2])
+		pr := repo.pullRequest(number)
+		if pr == nil {
+			return nil, pullRequestNotFound(number)
+		}
+		result[match[1]] = map[string]interface{}{"files": filesConnection(pr, nil)}
+	}
+	return result, nil
+}
+
+func filesConnection(pr *PullRequest, variables map[string]interface{}) interface{} {
+	start, end, pageInfo := connectionPage(len(pr.Files), variables)
+	nodes := make([]fileNode, 0, end-start)
+	for _, f := range pr.Files[start:end] {
+		nodes = append(nodes, toFileNode(f))
+	}
+	return map[string]interface{}{"nodes": nodes, "pageInfo": pageInfo}
+}
+
+// repositoryLabels - the PR $number and the existing label named $labels, GitHub searches the labels by name
+func repositoryLabels(repo *Repository, variables map[string]interface{}) interface{} {
+	var pullRequest interface{}
+	if pr := repo.pullRequest(intVariable(variables, "number")); pr != nil {
+		pullRequest = map[string]interface{}{"id": pullRequestID(repositoryName(variables), pr.Data.Number)}
+	}
+	labels := make([]labelNode, 0)
+	query := strings.ToLower(stringVariable(variables, "labels"))
+	for _, name := range repo.ExistingLabels {
+		if strings.Contains(strings.ToLower(name), query) {
+			labels = append(labels, labelNode{ID: "LA_" + name, Name: name})
+		}
+	}
+	return map[string]interface{}{
+		"pullRequest": pullRequest,
+		"labels":      map[string]interface{}{"nodes": labels},
+	}
+}
+
+func (s *Server) createPullRequestMutation(variables map[string]interface{}) (interface{}, *graphQLError) {
+	input, _ := variables["input"].(map[string]interface{})
+	repoName := strings.TrimPrefix(stringVariable(input, "repositoryId"), "R_")
+	if _, ok := s.fixture.Repositories[repoName]; !ok {
+		return nil, &graphQLError{Type: "NOT_FOUND", Message: "Could not resolve to a node with the global id of '" + stringVariable(input, "repositoryId") + "'"}
+	}
+	repo := s.fixture.Repository(repoName)
+
+	request := github.CreatePullRequest{
+		Title: stringVariable(input, "title"),
+		Body:  stringVariable(input, "body"),
+		Base:  stringVariable(input, "baseRefName"),
+		Head:  stringVariable(input, "headRefName"),
+	}
+	request.Draft, _ = input["draft"].(bool)
+	if request.Title == "" || request.Head == "" || request.Base == "" {
+		return nil, &graphQLError{Type: "UNPROCESSABLE", Message: "title, headRefName and baseRefName are required"}
+	}
+	pr, message := repo.createPullRequest(repoName, request)
+	if pr == nil {
+		return nil, &graphQLError{Type: "UNPROCESSABLE", Message: message}
+	}
+	return map[string]interface{}{
+		"createPullRequest": map[string]interface{}{"pullRequest": toPullRequestNode(pr, repoName)},
+	}, nil
+}
+
+func (s *Server) addLabelsToLabelable(variables map[string]interface{}) (interface{}, *graphQLError) {
+	input, _ := variables["input"].(map[string]interface{})
+	id := stringVariable(input, "labelableId")
+	i := strings.LastIndex(id, "#")
+	if !strings.HasPrefix(id, "PR_") || i < 0 {
+		return nil, &graphQLError{Type: "NOT_FOUND", Message: "Could not resolve to a node with the global id of '" + id + "'"}
+	}
+	repoName := id[len("PR_"):i]
+	number, _ := strconv.Atoi(id[i+1:])
+	repo, ok := s.fixture.Repositories[repoName]
+	if !ok || repo.pullRequest(number) == nil {
+		return nil, &graphQLError{Type: "NOT_FOUND", Message: "Could not resolve to a node with the global id of '" + id + "'"}
+	}
+
+	labelIDs, _ := input["labelIds"].([]interface{})
+	for _, labelID := range labelIDs {
+		name := strings.TrimPrefix(fmt.Sprint(labelID), "LA_")
+		repo.Labels[number] = append(repo.Labels[number], name)
+	}
+
+	labels := make([]labelNode, 0)
+	for _, name := range repo.Labels[number] {
+		labels = append(labels, labelNode{ID: "LA_" + name, Name: name})
+	}
+	return map[string]interface{}{
+		"addLabelsToLabelable": map[string]interface{}{
+			"labelable": map[string]interface{}{"labels": map[string]interface{}{"nodes": labels}},
+		},
+	}, nil
+}
+
+// connectionPage - bounds of the page of count nodes after the cursor $after, the cursors are offsets
+func connectionPage(count int, variables map[string]interface{}) (int, int, graphQLPageInfo) {
+	start, err := strconv.Atoi(stringVariable(variables, "after"))
+	if err != nil || start < 0 {
+		start = 0
+	}
+	if start > count {
+		start = count
+	}
+	end := start + connectionPageSize
+	if end > count {
+		end = count
+	}
+	return start, end, graphQLPageInfo{HasNextPage: end < count, EndCursor: strconv.Itoa(end)}
+}
+
+func toReleaseNode(release github.ReleaseData) releaseNode {
+	return releaseNode{
+		Name:         release.Name,
+		TagName:      release.Tag,
+		Description:  release.Body,
+		IsPrerelease: release.Prerelease,
+		IsDraft:      release.Draft,
+		PublishedAt:  release.PublishedAt,
+	}
+}
+
+func toPullRequestNode(pr *PullRequest, repoName string) pullRequestNode {
+	node := pullRequestNode{
+		Number:      pr.Data.Number,
+		URL:         pr.Data.HtmlUrl,
+		Title:       pr.Data.Title,
+		Body:        pr.Data.Body,
+		ClosedAt:    pr.Data.ClosedAt,
+		Merged:      pr.Merged,
+		HeadRefName: pr.Data.Head.Ref,
+	}
+	if pr.Data.MergeCommitSha != "" {
+		node.MergeCommit = &struct {
+			Oid string `json:"oid"`
+		}{Oid: pr.Data.MergeCommitSha}
+	}
+	node.Comments.TotalCount = pr.Data.Comments
+	if pr.Data.User.Login != "" {
+		node.Author = &loginNode{Login: pr.Data.User.Login}
+	}
+	node.Labels.Nodes = make([]nameNode, 0, len(pr.Data.Labels))
+	for _, label := range pr.Data.Labels {
+		node.Labels.Nodes = append(node.Labels.Nodes, nameNode{Name: label.Name})
+	}
+	if i := strings.Index(pr.Data.Head.Label, ":"); i >= 0 {
+		node.HeadRepositoryOwner = &loginNode{Login: pr.Data.Head.Label[:i]}
+	}
+	node.BaseRepository.NameWithOwner = repoName
+	return node
+}
+
+// toFileNode - file with the change type of the GraphQL API
+func toFileNode(f github.File) fileNode {
+	changeType := strings.ToUpper(f.Status)
+	if f.Status == "removed" {
+		changeType = "DELETED"
+	}
+	return fileNode{Path: f.Filename, Additions: f.Additions, Deletions: f.Deletions, ChangeType: changeType}
+}
+
+func repositoryName(variables map[string]interface{}) string {
+	return stringVariable(variables, "owner") + "/" + stringVariable(variables, "name")
+}
+
+func pullRequestID(repoName string, number int) string {
+	return fmt.Sprintf("PR_%s#%d", repoName, number)
+}
+
+func stringVariable(variables map[string]interface{}, name string) string {
+	value, _ := variables[name].(string)
+	return value
+}
+
+// intVariable - integer variable, JSON numbers are decoded as float64
+func intVariable(variables map[string]interface{}, name string) int {
+	value, _ := variables[name].(float64)
+	return int(value)
+}
+
+func repositoryNotFound(name string) *graphQLError {
+	return &graphQLError{Type: "NOT_FOUND", Message: fmt.Sprintf("Could not resolve to a Repository with the name '%s'.", name)}
+}
+
+func pullRequestNotFound(number int) *graphQLError {
+	return &graphQLError{Type: "NOT_FOUND", Message: fmt.Sprintf("Could not resolve to a PullRequest with the number of %d.", number)}
+}
@@ -0,0 +1,359 @@
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"upgradebot/config"
+	"upgradebot/pkg/github"
+)
+
+// defaultPerPage - page size of GitHub when per_page is not given
+const defaultPerPage = 30
+
+var (
+	repoPathMatcher       = regexp.MustCompile(`^/repos/([^/]+/[^/]+)(/.*)$`)
+	releaseTagPathMatcher = regexp.MustCompile(`^/releases/tags/(.+)$`)
+	comparePathMatcher    = regexp.MustCompile(`^/compare/(.+)\.\.\.(.+)$`)
+	pullFilesPathMatcher  = regexp.MustCompile(`^/pulls/(\d+)/files$`)
+	labelsPathMatcher     = regexp.MustCompile(`^/issues/(\d+)/labels$`)
+	searchRepoMatcher     = regexp.MustCompile(`repo:(\S+)`)
+)
+
+// Server - fake of the GitHub REST API endpoints used by the bot, serving and updating a Fixture in memory:
+// releases, releases/tags, compare, pulls (list and create), pulls/files, search/issues and issues/labels.
+// Lists are paginated with `Link` headers like GitHub. The GraphQL queries of the bot are served on /graphql.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	fixture  *Fixture
+	requests []string
+	failures []Failure
+}
+
+// Failure - response answered instead of serving the fixture, e.g. a 502 or a GraphQL error with a 200
+type Failure struct {
+	Status int
+	Header map[string]string
+	// Message - message of the error body, the status text by default
+	Message string
+	// GraphQLType - type of the GraphQL error of the response if any, e.g. RATE_LIMITED
+	GraphQLType string
+}
+
+// NewServer - start a fake GitHub serving the fixture, it must be closed after use
+func NewServer(fixture *Fixture) *Server {
+	if fixture == nil {
+		fixture = NewFixture()
+	}
+	s := &Server{fixture: fixture}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Configure - point the GitHub API URLs of the config to the fake server
+func (s *Server) Configure(cfg *config.Config) {
+	cfg.GithubAPIUrl = s.URL
+	cfg.GithubGraphQLUrl = s.URL + "/graphql"
+	cfg.QuorumAPIUrl = s.URL + "/repos/" + cfg.QuorumRepoName
+	cfg.GethGithubAPIUrl = s.URL + "/repos/" + cfg.GethRepoName
+}
+
+// Fixture - state of the fake GitHub, including the PRs and labels created by the bot.
+// It must not be modified while requests are served.
+func (s *Server) Fixture() *Fixture {
+	return s.fixture
+}
+
+// FailNext - answer the next requests with the failures, in order, before serving the fixture again
+func (s *Server) FailNext(failures ...Failure) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failures...)
+}
+
+// Requests - method and URL of all the requests received
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+
+	if len(s.failures) > 0 {
+		failure := s.failures[0]
+		s.failures = s.failures[1:]
+		writeFailure(w, failure)
+		return
+	}
+
+	if r.URL.Path == "/graphql" && r.Method == http.MethodPost {
+		s.graphQL(w, r)
+		return
+	}
+	if r.URL.Path == "/search/issues" && r.Method == http.MethodGet {
+		s.searchIssues(w, r)
+		return
+	}
+
+	match := repoPathMatcher.FindStringSubmatch(r.URL.Path)
+	if match == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if _, ok := s.fixture.Repositories[match[1]]; !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	repo := s.fixture.Repository(match[1])
+	path := match[2]
+
+	switch {
+	case r.Method == http.MethodGet && path == "/releases":
+		writePage(w, r, repo.Releases)
+	case r.Method == http.MethodGet && releaseTagPathMatcher.MatchString(path):
+		getRelease(w, repo, releaseTagPathMatcher.FindStringSubmatch(path)[1])
+	case r.Method == http.MethodGet && comparePathMatcher.MatchString(path):
+		compareMatch := comparePathMatcher.FindStringSubmatch(path)
+		compare(w, r, repo, compareMatch[1], compareMatch[2])
+	case r.Method == http.MethodGet && path == "/pulls":
+		listPullRequests(w, r, repo)
+	case r.Method == http.MethodPost && path == "/pulls":
+		createPullRequest(w, r, repo, match[1])
+	case r.Method == http.MethodGet && pullFilesPathMatcher.MatchString(path):
+		number, _ := strconv.Atoi(pullFilesPathMatcher.FindStringSubmatch(path)[1])
+		getPullRequestFiles(w, r, repo, number)
+	case r.Method == http.MethodPost && labelsPathMatcher.MatchString(path):
+		number, _ := strconv.Atoi(labelsPathMatcher.FindStringSubmatch(path)[1])
+		addLabels(w, r, repo, number)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func getRelease(w http.ResponseWriter, repo *Repository, tag string) {
+	for _, release := range repo.Releases {
+		if release.Tag == tag {
+			writeJson(w, http.StatusOK, release)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+// compare - the commits are paginated, the files are only returned on the first page like GitHub
+func compare(w http.ResponseWriter, r *http.Request, repo *Repository, base string, target string) {
+	changes, ok := repo.Compares[base+"..."+target]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	start, end := paginate(w, r, len(changes.Commits))
+	page := github.CommitChanges{Commits: changes.Commits[start:end]}
+	if start == 0 {
+		page.Files = changes.Files
+	}
+	writeJson(w, http.StatusOK, page)
+}
+
+func listPullRequests(w http.ResponseWriter, r *http.Request, repo *Repository) {
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		state = "open"
+	}
+	prs := make([]github.PullRequestData, 0)
+	for _, pr := range repo.PullRequests {
+		if state == "all" || pr.State == state {
+			prs = append(prs, pr.Data)
+		}
+	}
+	writePage(w, r, prs)
+}
+
+func createPullRequest(w http.ResponseWriter, r *http.Request, repo *Repository, repoName string) {
+	request := github.CreatePullRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	if request.Title == "" || request.Head == "" || request.Base == "" {
+		writeValidationError(w, "missing_field", "title, head and base are required")
+		return
+	}
+
+	pr, message := repo.createPullRequest(repoName, request)
+	if pr == nil {
+		writeValidationError(w, "custom", message)
+		return
+	}
+	writeJson(w, http.StatusCreated, pr.Data)
+}
+
+func getPullRequestFiles(w http.ResponseWriter, r *http.Request, repo *Repository, number int) {
+	pr := repo.pullRequest(number)
+	if pr == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	writePage(w, r, pr.Files)
+}
+
+func addLabels(w http.ResponseWriter, r *http.Request, repo *Repository, number int) {
+	if repo.pullRequest(number) == nil {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	request := github.LabelsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	repo.Labels[number] = append(repo.Labels[number], request.Labels...)
+
+	labels := github.LabelsRequestData{}
+	for i, name := range repo.Labels[number] {
+		labels = append(labels, github.LabelRequestData{ID: i + 1, Name: name})
+	}
+	writeJson(w, http.StatusOK, labels)
+}
+
+// searchIssues - search of the merged PRs of a repository by commit SHA prefixes: `repo:owner/name is:pr is:merged merged sha1 sha2...`
+func (s *Server) searchIssues(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	repoMatch := searchRepoMatcher.FindStringSubmatch(query)
+	if repoMatch == nil {
+		writeValidationError(w, "invalid", "The search query must contain a repo: qualifier")
+		return
+	}
+	repo, ok := s.fixture.Repositories[repoMatch[1]]
+	if !ok {
+		writeValidationError(w, "invalid", "The listed repository cannot be searched")
+		return
+	}
+
+	shas := make([]string, 0)
+	for _, term := range strings.Fields(query) {
+		if !strings.Contains(term, ":") && term != "merged" {
+			shas = append(shas, term)
+		}
+	}
+
+	items := make([]github.PullRequestData, 0)
+	for _, pr := range repo.PullRequests {
+		if pr.Merged && mergedAnyCommit(pr, shas) {
+			items = append(items, pr.Data)
+		}
+	}
+
+	start, end := paginate(w, r, len(items))
+	writeJson(w, http.StatusOK, struct {
+		TotalCount int                      `json:"total_count"`
+		Items      []github.PullRequestData `json:"items"`
+	}{TotalCount: len(items), Items: items[start:end]})
+}
+
+func mergedAnyCommit(pr *PullRequest, shas []string) bool {
+	for _, commit := range pr.Commits {
+		for _, sha := range shas {
+			if strings.HasPrefix(commit, sha) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writePage - write the page of items requested with the page and per_page params, items must be a slice
+func writePage(w http.ResponseWriter, r *http.Request, items interface{}) {
+	value := reflect.ValueOf(items)
+	start, end := paginate(w, r, value.Len())
+	writeJson(w, http.StatusOK, value.Slice(start, end).Interface())
+}
+
+// paginate - bounds of the requested page of count items, setting the `Link` header to the next and last pages
+func paginate(w http.ResponseWriter, r *http.Request, count int) (int, int) {
+	query := r.URL.Query()
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = defaultPerPage
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	lastPage := (count + perPage - 1) / perPage
+	if page < lastPage {
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next", <%s>; rel="last"`, pageURL(r, page+1), pageURL(r, lastPage)))
+	}
+
+	start := (page - 1) * perPage
+	if start > count {
+		start = count
+	}
+	end := start + perPage
+	if end > count {
+		end = count
+	}
+	return start, end
+}
+
+func pageURL(r *http.Request, page int) string {
+	u := *r.URL
+	u.Scheme = "http"
+	u.Host = r.Host
+	query := u.Query()
+	query.Set("page", strconv.Itoa(page))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func writeJson(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+// errorBody - body of the GitHub error responses
+type errorBody struct {
+	Message string                  `json:"message"`
+	Errors  []github.APIErrorDetail `json:"errors,omitempty"`
+}
+
+func writeFailure(w http.ResponseWriter, failure Failure) {
+	for k, v := range failure.Header {
+		w.Header().Set(k, v)
+	}
+	if failure.GraphQLType != "" {
+		writeJson(w, failure.Status, map[string]interface{}{"data": nil, "errors": []graphQLError{{Type: failure.GraphQLType, Message: "failure"}}})
+		return
+	}
+	message := failure.Message
+	if message == "" {
+		message = http.StatusText(failure.Status)
+	}
+	writeError(w, failure.Status, message)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, errorBody{Message: message})
+}
+
+func writeValidationError(w http.ResponseWriter, code string, message string) {
+	writeJson(w, http.StatusUnprocessableEntity, errorBody{
+		Message: "Validation Failed",
+		Errors:  []github.APIErrorDetail{{Code: code, Message: message}},
+	})
+}
@@ -1,10 +1,13 @@
 package github
 
+import "context"
+
 type Github interface {
-	GetGethReleaseData(tag string) ReleaseData
-	GetGethTagComparison(base string, target string) TagCompare
-	GetNextReleaseFrom(baseTag string) ReleaseData
-	CreateQuorumPullRequest(branchName string, data ReleaseData, prBody string) (*PullRequestData, error)
-	FindOpenUpgradePullRequest(targetTag string) *PullRequestData
-	AddLabelsToIssue(issueNumber int, labels ...string) *LabelsRequestData
+	GetGethReleaseData(ctx context.Context, tag string) (ReleaseData, error)
+	GetGethTagComparison(ctx context.Context, base string, target string) (TagCompare, error)
+	GetUpgradeReleases(ctx context.Context, baseTag string, targetTag string) (UpgradeReleases, error)
+	CreateQuorumPullRequest(ctx context.Context, branchName string, data ReleaseData, prBody string) (*PullRequestData, error)
+	FindOpenUpgradePullRequest(ctx context.Context, targetTag string) (*PullRequestData, error)
+	GetOpenPullRequests(ctx context.Context) ([]PullRequestData, error)
+	AddLabelsToIssue(ctx context.Context, issueNumber int, labels ...string) (*LabelsRequestData, error)
 }
@@ -0,0 +1,299 @@
+package github_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"upgradebot/config"
+	"upgradebot/pkg/github"
+	"upgradebot/pkg/github/fake"
+	"upgradebot/pkg/github/graphql"
+	"upgradebot/pkg/github/http"
+)
+
+const (
+	shaA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	shaB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	shaC = "cccccccccccccccccccccccccccccccccccccccc"
+)
+
+// newTestFixture - geth releases and PRs whose files are consistent with the comparison, as the GraphQL backend
+// aggregates the files of the PRs, and an open upgrade PR in Quorum
+func newTestFixture(cfg *config.Config) *fake.Fixture {
+	fixture := fake.NewFixture()
+
+	geth := fixture.Repository(cfg.GethRepoName)
+	geth.Releases = []github.ReleaseData{
+		{Name: "Rc", Tag: "v1.10.3-rc1", Prerelease: true},
+		{Name: "Second", Tag: "v1.10.2", Body: "second"},
+		{Name: "First", Tag: "v1.10.1", Body: "first", PublishedAt: "2021-07-01T00:00:00Z"},
+		{Name: "Base", Tag: "v1.10.0"},
+	}
+	fileA := github.File{Status: "modified", Filename: "core/a.go", Additions: 3, Deletions: 1, Changes: 4}
+	fileB := github.File{Status: "added", Filename: "core/b.go", Additions: 10, Changes: 10}
+	fileC := github.File{Status: "removed", Filename: "core/c.go", Deletions: 5, Changes: 5}
+	fileA2 := github.File{Status: "modified", Filename: "core/a.go", Additions: 1, Deletions: 1, Changes: 2}
+	geth.AddMergedPullRequest(github.PullRequestData{
+		Number: 11, Title: "core: first", HtmlUrl: "https://github.com/ethereum/go-ethereum/pull/11",
+		MergeCommitSha: shaA, User: github.User{Login: "alice"}, Labels: []github.Label{{Name: "core"}},
+	}, []github.File{fileA, fileB}, shaA)
+	geth.AddMergedPullRequest(github.PullRequestData{
+		Number: 12, Title: "core: second", HtmlUrl: "https://github.com/ethereum/go-ethereum/pull/12",
+		MergeCommitSha: shaB, User: github.User{Login: "bob"}, Labels: []github.Label{},
+	}, []github.File{fileA2, fileC}, shaB)
+	geth.Compares["v1.10.0...v1.10.2"] = github.CommitChanges{
+		Commits: []github.Commit{
+			{Sha: shaA, Commit: github.CommitDetails{Message: "core: first (#11)"}},
+			{Sha: shaB, Commit: github.CommitDetails{Message: "Merge pull request #12 from bob/second"}},
+			{Sha: shaC, Commit: github.CommitDetails{Message: "params: begin v1.10.3 release cycle"}},
+		},
+		Files: []github.File{
+			{Status: "modified", Filename: "core/a.go", Additions: 4, Deletions: 2, Changes: 6},
+			fileB,
+			fileC,
+		},
+	}
+
+	quorum := fixture.Repository(cfg.QuorumRepoName)
+	quorum.ExistingLabels = []string{cfg.GithubLabel, "bug"}
+	quorum.PullRequests = []*fake.PullRequest{
+		{Data: github.PullRequestData{Number: 1, Title: "Fix a bug", Head: github.PullRequestHead{Ref: "fix", Label: "someone:fix"}}, State: "open"},
+		{Data: github.PullRequestData{Number: 2, Title: github.UpgradePullRequestTitle("v1.10.1"),
+			Head: github.PullRequestHead{Ref: "upgrade/v1.10.1", Label: cfg.QuorumBotOwner + ":upgrade/v1.10.1"}}, State: "open"},
+		{Data: github.PullRequestData{Number: 3, Title: github.UpgradePullRequestTitle("v1.10.0")}, State: "closed"},
+	}
+	return fixture
+}
+
+func releaseTags(releases []github.ReleaseData) []string {
+	tags := make([]string, len(releases))
+	for i, r := range releases {
+		tags[i] = r.Tag
+	}
+	return tags
+}
+
+func TestGithub(t *testing.T) {
+	backends := []struct {
+		name      string
+		newGithub func(cfg *config.Config) github.Github
+	}{
+		{name: config.GithubBackendREST, newGithub: http.NewGithub},
+		{name: config.GithubBackendGraphQL, newGithub: graphql.NewGithub},
+	}
+	tests := []struct {
+		name string
+		run  func(t *testing.T, api github.Github, server *fake.Server, cfg *config.Config)
+	}{
+		{
+			name: "prereleases with the latest policy",
+			run: func(t *testing.T, api github.Github, server *fake.Server, cfg *config.Config) {
+				cfg.UpgradePolicy = config.UpgradePolicyLatest
+				cfg.IncludePrereleases = true
+				upgrade, err := api.GetUpgradeReleases(context.Background(), "v1.10.0", "")
+				if err != nil {
+					t.Fatalf("GetUpgradeReleases: %v", err)
+				}
+				want := []string{"v1.10.1", "v1.10.2", "v1.10.3-rc1"}
+				if got := releaseTags(upgrade.Releases); !reflect.DeepEqual(got, want) {
+					t.Errorf("releases = %v, want %v", got, want)
+				}
+				first := upgrade.Releases[0]
+				if upgrade.Base.Name != "Base" || first.Name != "First" || first.Body != "first" || first.PublishedAt != "2021-07-01T00:00:00Z" || !upgrade.Target.Prerelease {
+					t.Errorf("upgrade = %+v", upgrade)
+				}
+			},
+		},
+		{
+			name: "upgrade releases",
+			run: func(t *testing.T, api github.Github, server *fake.Server, cfg *config.Config) {
+				upgrade, err := api.GetUpgradeReleases(context.Background(), "v1.10.0", "")
+				if err != nil {
+					t.Fatalf("GetUpgradeReleases: %v", err)
+				}
+				if upgrade.Target.Tag != "v1.10.1" || !reflect.DeepEqual(releaseTags(upgrade.Releases), []string{"v1.10.1"}) {
+					t.Errorf("upgrade = %+v", upgrade)
+				}
+			},
+		},
+		{
+			name: "release",
+			run: func(t *testing.T, api github.Github, server *fake.Server, cfg *config.Config) {
+				release, err := api.GetGethReleaseData(context.Background(), "v1.10.1")
+				if err != nil {
+					t.Fatalf("GetGethReleaseData: %v", err)
+				}
+				if release.Tag != "v1.10.1" || release.Name != "First" || release.Body != "first" {
+					t.Errorf("release = %+v", release)
+				}
+				if _, err := api.GetGethReleaseData(context.Background(), "v9.9.9"); !errors.Is(err, github.ErrNotFound) {
+					t.Errorf("GetGethReleaseData of an unknown tag = %v, want %v", err, github.ErrNotFound)
+				}
+			},
+		},
+		{
+			name: "tag comparison",
+			run: func(t *testing.T, api github.Github, server *fake.Server, cfg *config.Config) {
+				compare, err := api.GetGethTagComparison(context.Background(), "v1.10.0", "v1.10.2")
+				if err != nil {
+					t.Fatalf("GetGethTagComparison: %v", err)
+				}
+				if len(compare.PullRequests) != 2 {
+					t.Fatalf("PRs = %+v, want 2 PRs", compare.PullRequests)
+				}
+				geth := server.Fixture().Repository(cfg.GethRepoName)
+				for i, pr := range compare.PullRequests {
+					want := geth.PullRequests[i]
+					if pr.Data.Number != want.Data.Number || pr.Data.Title != want.Data.Title || pr.Data.HtmlUrl != want.Data.HtmlUrl ||
+						pr.Data.MergeCommitSha != want.Data.MergeCommitSha || pr.Data.User.Login != want.Data.User.Login {
+						t.Errorf("PR #%d = %+v, want %+v", i, pr.Data, want.Data)
+					}
+					if !reflect.DeepEqual(pr.Files, want.Files) {
+						t.Errorf("files of PR #%d = %+v, want %+v", pr.Data.Number, pr.Files, want.Files)
+					}
+				}
+				if want := geth.Compares["v1.10.0...v1.10.2"].Files; !reflect.DeepEqual(compare.Files, want) {
+					t.Errorf("files = %+v, want %+v", compare.Files, want)
+				}
+				if _, err := api.GetGethTagComparison(context.Background(), "v1.9.0", "v1.10.2"); !errors.Is(err, github.ErrNotFound) {
+					t.Errorf("GetGethTagComparison of an unknown tag = %v, want %v", err, github.ErrNotFound)
+				}
+			},
+		},
+		{
+			name: "open PRs",
+			run: func(t *testing.T, api github.Github, server *fake.Server, cfg *config.Config) {
+				prs, err := api.GetOpenPullRequests(context.Background())
+				if err != nil {
+					t.Fatalf("GetOpenPullRequests: %v", err)
+				}
+				if len(prs) != 2 || prs[0].Number != 1 || prs[1].Number != 2 || prs[1].Head.Label != cfg.QuorumBotOwner+":upgrade/v1.10.1" {
+					t.Errorf("open PRs = %+v, want #1 and #2", prs)
+				}
+				pr, err := api.FindOpenUpgradePullRequest(context.Background(), "v1.10.1")
+				if err != nil || pr == nil || pr.Number != 2 {
+					t.Errorf("FindOpenUpgradePullRequest(v1.10.1) = %+v, %v, want #2", pr, err)
+				}
+				pr, err = api.FindOpenUpgradePullRequest(context.Background(), "v1.10.0")
+				if err != nil || pr != nil {
+					t.Errorf("FindOpenUpgradePullRequest(v1.10.0) = %+v, %v, want none", pr, err)
+				}
+			},
+		},
+		{
+			name: "create a labelled PR",
+			run: func(t *testing.T, api github.Github, server *fake.Server, cfg *config.Config) {
+				release := github.ReleaseData{Tag: "v1.10.2"}
+				pr, err := api.CreateQuorumPullRequest(context.Background(), "upgrade/v1.10.2", release, "the body")
+				if err != nil {
+					t.Fatalf("CreateQuorumPullRequest: %v", err)
+				}
+				if pr.Number != 4 || pr.Title != github.UpgradePullRequestTitle("v1.10.2") || pr.Body != "the body" {
+					t.Errorf("PR = %+v", pr)
+				}
+				labels, err := api.AddLabelsToIssue(context.Background(), pr.Number, cfg.GithubLabel)
+				if err != nil {
+					t.Fatalf("AddLabelsToIssue: %v", err)
+				}
+				if len(*labels) != 1 || (*labels)[0].Name != cfg.GithubLabel {
+					t.Errorf("labels = %+v, want %s", labels, cfg.GithubLabel)
+				}
+
+				quorum := server.Fixture().Repository(cfg.QuorumRepoName)
+				created := quorum.PullRequests[len(quorum.PullRequests)-1]
+				if created.State != "open" || !created.Draft || created.Base != cfg.QuorumBaseBranch ||
+					created.Data.Head.Label != cfg.QuorumBotOwner+":upgrade/v1.10.2" || created.Data.Body != "the body" {
+					t.Errorf("created PR = %+v", created)
+				}
+				if !reflect.DeepEqual(quorum.Labels[pr.Number], []string{cfg.GithubLabel}) {
+					t.Errorf("labels of the created PR = %v", quorum.Labels[pr.Number])
+				}
+
+				if _, err := api.CreateQuorumPullRequest(context.Background(), "upgrade/v1.10.2", release, "again"); err == nil {
+					t.Errorf("CreateQuorumPullRequest of an open head: expected an error")
+				}
+				if _, err := api.AddLabelsToIssue(context.Background(), 99, cfg.GithubLabel); !errors.Is(err, github.ErrNotFound) {
+					t.Errorf("AddLabelsToIssue of an unknown PR = %v, want %v", err, github.ErrNotFound)
+				}
+			},
+		},
+	}
+
+	for _, backend := range backends {
+		for _, test := range tests {
+			t.Run(backend.name+"/"+test.name, func(t *testing.T) {
+				cfg := config.Default()
+				cfg.GithubBackend = backend.name
+				server := fake.NewServer(newTestFixture(cfg))
+				defer server.Close()
+				server.Configure(cfg)
+
+				test.run(t, backend.newGithub(cfg), server, cfg)
+			})
+		}
+	}
+}
+
+func TestRESTRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure fake.Failure
+	}{
+		{name: "server error", failure: fake.Failure{Status: 502}},
+		{name: "secondary rate limit without headers", failure: fake.Failure{Status: 403,
+			Message: "You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.Default()
+			server := fake.NewServer(newTestFixture(cfg))
+			defer server.Close()
+			server.Configure(cfg)
+			server.FailNext(test.failure)
+
+			release, err := http.NewGithub(cfg).GetGethReleaseData(context.Background(), "v1.10.1")
+			if err != nil {
+				t.Fatalf("GetGethReleaseData: %v", err)
+			}
+			if release.Tag != "v1.10.1" {
+				t.Errorf("release = %+v", release)
+			}
+			if requests := server.Requests(); len(requests) != 2 {
+				t.Errorf("requests = %v, want the request and its retry", requests)
+			}
+		})
+	}
+}
+
+func TestGraphQLRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure fake.Failure
+	}{
+		{name: "server error", failure: fake.Failure{Status: 502}},
+		{name: "rate limited", failure: fake.Failure{Status: 200, Header: map[string]string{"Retry-After": "0"}, GraphQLType: "RATE_LIMITED"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.GithubBackend = config.GithubBackendGraphQL
+			server := fake.NewServer(newTestFixture(cfg))
+			defer server.Close()
+			server.Configure(cfg)
+			server.FailNext(test.failure)
+
+			release, err := graphql.NewGithub(cfg).GetGethReleaseData(context.Background(), "v1.10.1")
+			if err != nil {
+				t.Fatalf("GetGethReleaseData: %v", err)
+			}
+			if release.Tag != "v1.10.1" {
+				t.Errorf("release = %+v", release)
+			}
+			if requests := server.Requests(); len(requests) != 2 {
+				t.Errorf("requests = %v, want the query and its retry", requests)
+			}
+		})
+	}
+}
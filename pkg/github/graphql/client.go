@@ -0,0 +1,116 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	nethttp "net/http"
+	"strings"
+
+	"upgradebot/pkg/github"
+	"upgradebot/pkg/github/http"
+)
+
+// GraphQLError - error returned by the GitHub GraphQL API with a 200 response
+type GraphQLError struct {
+	Type    string        `json:"type"`
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path"`
+}
+
+func (e *GraphQLError) Error() string {
+	if e.Type == "" {
+		return "graphql: " + e.Message
+	}
+	return fmt.Sprintf("graphql: %s: %s", e.Type, e.Message)
+}
+
+// Is - a GraphQLError matches ErrNotFound and ErrRateLimited from its type
+func (e *GraphQLError) Is(target error) bool {
+	switch target {
+	case github.ErrNotFound:
+		return e.Type == "NOT_FOUND"
+	case github.ErrRateLimited:
+		return e.Type == "RATE_LIMITED"
+	default:
+		return false
+	}
+}
+
+// graphQLClient - send GraphQL queries through the HTTPClient, sharing its authentication, rate limiting and retries
+type graphQLClient struct {
+	httpClient *http.HTTPClient
+	url        string
+}
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []GraphQLError  `json:"errors"`
+}
+
+// query - send a query without side effects and decode the `data` of the response into data, the first GraphQL error is returned.
+// Queries are retried on network errors and 5xx.
+func (c *graphQLClient) query(ctx context.Context, query string, variables map[string]interface{}, data interface{}) error {
+	return c.send(ctx, query, variables, data, true)
+}
+
+// mutate - send a mutation and decode the `data` of the response into data, the first GraphQL error is returned.
+// A mutation is retried only when rate-limited, as GitHub rejects it before processing it.
+func (c *graphQLClient) mutate(ctx context.Context, mutation string, variables map[string]interface{}, data interface{}) error {
+	return c.send(ctx, mutation, variables, data, false)
+}
+
+// send - POST the request, waiting for the rate limit reset and retrying when GraphQL answers RATE_LIMITED
+func (c *graphQLClient) send(ctx context.Context, query string, variables map[string]interface{}, data interface{}, idempotent bool) error {
+	body, err := json.Marshal(graphQLRequest{Query: query, Variables: variables})
+	if err != nil {
+		return fmt.Errorf("json request: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		var response []byte
+		var header nethttp.Header
+		if idempotent {
+			response, header, err = c.httpClient.DoQuery(ctx, c.url, bytes.NewReader(body))
+		} else {
+			// without the header, the rate limit is waited for as a secondary rate limit
+			response, err = c.httpClient.DoPost(ctx, c.url, bytes.NewReader(body))
+		}
+		if err != nil {
+			return err
+		}
+
+		result := graphQLResponse{}
+		if err := json.Unmarshal(response, &result); err != nil {
+			return fmt.Errorf("parse json: %w", err)
+		}
+		if len(result.Errors) > 0 {
+			if errors.Is(&result.Errors[0], github.ErrRateLimited) && c.httpClient.RetryRateLimited(ctx, header, attempt) {
+				log.Printf("POST %s: %s, retry #%d\n", c.url, result.Errors[0].Error(), attempt+1)
+				continue
+			}
+			return &result.Errors[0]
+		}
+		if err := json.Unmarshal(result.Data, data); err != nil {
+			return fmt.Errorf("parse json: %w", err)
+		}
+		return nil
+	}
+}
+
+// splitRepoName - owner and name of a repository, e.g. `ethereum/go-ethereum`
+func splitRepoName(repoName string) (string, string) {
+	parts := strings.SplitN(repoName, "/", 2)
+	if len(parts) != 2 {
+		return repoName, ""
+	}
+	return parts[0], parts[1]
+}
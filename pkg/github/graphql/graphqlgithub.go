@@ -0,0 +1,432 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"upgradebot/config"
+	"upgradebot/pkg/github"
+	"upgradebot/pkg/github/http"
+	"upgradebot/pkg/workerpool"
+)
+
+// filesBatchSize - number of PRs per query fetching their files
+const filesBatchSize = 20
+
+// GraphQLGithub - github.Github backed by the GitHub GraphQL v4 API: the PRs of a tag comparison are the PRs associated
+// with its commits, and their files are fetched by batches, instead of one search per 28 commits and one request per PR
+type GraphQLGithub struct {
+	client *graphQLClient
+	config *config.Config
+
+	gethOwner, gethName     string
+	quorumOwner, quorumName string
+}
+
+func NewGithub(config *config.Config) github.Github {
+	api := &GraphQLGithub{
+		client: &graphQLClient{httpClient: http.NewHTTPClient(config), url: config.GithubGraphQLUrl},
+		config: config,
+	}
+	api.gethOwner, api.gethName = splitRepoName(config.GethRepoName)
+	api.quorumOwner, api.quorumName = splitRepoName(config.QuorumRepoName)
+	return api
+}
+
+// GetUpgradeReleases - get the go-ethereum releases from a specific version/tag to the target tag, or to the release chosen by the upgrade policy if no target is given
+func (api *GraphQLGithub) GetUpgradeReleases(ctx context.Context, baseTag string, targetTag string) (github.UpgradeReleases, error) {
+	releases, err := api.GetAllGethReleases(ctx)
+	if err != nil {
+		return github.UpgradeReleases{}, err
+	}
+	return github.SelectUpgrade(releases, baseTag, targetTag, github.UpgradePolicy(api.config.UpgradePolicy), api.config.IncludePrereleases)
+}
+
+// GetAllGethReleases - get all go-ethereum releases, from the latest to the oldest
+func (api *GraphQLGithub) GetAllGethReleases(ctx context.Context) ([]github.ReleaseData, error) {
+	releases := make([]github.ReleaseData, 0)
+	after := ""
+	for {
+		data := struct {
+			Repository struct {
+				Releases struct {
+					Nodes    []releaseNode `json:"nodes"`
+					PageInfo pageInfo      `json:"pageInfo"`
+				} `json:"releases"`
+			} `json:"repository"`
+		}{}
+		if err := api.client.query(ctx, releasesQuery, api.gethVariables(after), &data); err != nil {
+			return nil, fmt.Errorf("get geth releases: %w", err)
+		}
+		for _, r := range data.Repository.Releases.Nodes {
+			releases = append(releases, r.toReleaseData())
+		}
+		if !data.Repository.Releases.PageInfo.HasNextPage {
+			return releases, nil
+		}
+		after = data.Repository.Releases.PageInfo.EndCursor
+	}
+}
+
+// GetGethReleaseData - get go-ethereum release data based on a tag
+func (api *GraphQLGithub) GetGethReleaseData(ctx context.Context, tag string) (github.ReleaseData, error) {
+	variables := api.gethVariables("")
+	variables["tag"] = tag
+
+	data := struct {
+		Repository struct {
+			Release *releaseNode `json:"release"`
+		} `json:"repository"`
+	}{}
+	if err := api.client.query(ctx, releaseQuery, variables, &data); err != nil {
+		return github.ReleaseData{}, fmt.Errorf("get geth release %s: %w", tag, err)
+	}
+	if data.Repository.Release == nil {
+		return github.ReleaseData{}, fmt.Errorf("get geth release %s: %w", tag, github.ErrNotFound)
+	}
+	return data.Repository.Release.toReleaseData(), nil
+}
+
+// GetGethTagComparison - compare two geth tags and extract PR merged and files changed.
+// The GraphQL API has no files for a comparison, they are aggregated from the files of the PRs.
+func (api *GraphQLGithub) GetGethTagComparison(ctx context.Context, base string, target string) (github.TagCompare, error) {
+	prsData, err := api.getComparedPullRequests(ctx, base, target)
+	if err != nil {
+		return github.TagCompare{}, err
+	}
+
+	pullRequests, err := api.getPullRequestsFiles(ctx, prsData)
+	if err != nil {
+		return github.TagCompare{}, err
+	}
+
+	return github.TagCompare{PullRequests: pullRequests, Files: aggregateFiles(pullRequests)}, nil
+}
+
+// CreateQuorumPullRequest - create PR in the quorum repo
+func (api *GraphQLGithub) CreateQuorumPullRequest(ctx context.Context, branchName string, data github.ReleaseData, prBody string) (*github.PullRequestData, error) {
+	repository := struct {
+		Repository struct {
+			ID string `json:"id"`
+		} `json:"repository"`
+	}{}
+	if err := api.client.query(ctx, repositoryIDQuery, api.quorumVariables(), &repository); err != nil {
+		return nil, fmt.Errorf("get quorum repository: %w", err)
+	}
+
+	input := map[string]interface{}{
+		"repositoryId": repository.Repository.ID,
+		"title":        github.UpgradePullRequestTitle(data.Tag),
+		"body":         prBody,
+		"baseRefName":  api.config.QuorumBaseBranch,
+		"headRefName":  api.config.QuorumBotOwner + ":" + branchName, // created from QuorumBot fork
+		"draft":        true,
+	}
+	result := struct {
+		CreatePullRequest struct {
+			PullRequest pullRequestNode `json:"pullRequest"`
+		} `json:"createPullRequest"`
+	}{}
+	if err := api.client.mutate(ctx, createPullRequestMutation, map[string]interface{}{"input": input}, &result); err != nil {
+		return nil, fmt.Errorf("create PR: %w", err)
+	}
+
+	pr := result.CreatePullRequest.PullRequest.toPullRequestData()
+	return &pr, nil
+}
+
+// AddLabelsToIssue - adds some labels to the issue, the labels must exist in the quorum repo
+func (api *GraphQLGithub) AddLabelsToIssue(ctx context.Context, issueNumber int, labels ...string) (*github.LabelsRequestData, error) {
+	pullRequestID := ""
+	labelIDs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		variables := api.quorumVariables()
+		variables["number"] = issueNumber
+		variables["labels"] = label
+
+		data := struct {
+			Repository struct {
+				PullRequest *struct {
+					ID string `json:"id"`
+				} `json:"pullRequest"`
+				Labels struct {
+					Nodes []struct {
+						ID   string `json:"id"`
+						Name string `json:"name"`
+					} `json:"nodes"`
+				} `json:"labels"`
+			} `json:"repository"`
+		}{}
+		if err := api.client.query(ctx, labelsQuery, variables, &data); err != nil {
+			return nil, fmt.Errorf("add labels to #%d: %w", issueNumber, err)
+		}
+		if data.Repository.PullRequest == nil {
+			return nil, fmt.Errorf("add labels to #%d: %w", issueNumber, github.ErrNotFound)
+		}
+		pullRequestID = data.Repository.PullRequest.ID
+
+		labelID := ""
+		for _, l := range data.Repository.Labels.Nodes {
+			if strings.EqualFold(l.Name, label) {
+				labelID = l.ID
+			}
+		}
+		if labelID == "" {
+			return nil, fmt.Errorf("add labels to #%d: label %q: %w", issueNumber, label, github.ErrNotFound)
+		}
+		labelIDs = append(labelIDs, labelID)
+	}
+
+	input := map[string]interface{}{"labelableId": pullRequestID, "labelIds": labelIDs}
+	result := struct {
+		AddLabelsToLabelable struct {
+			Labelable struct {
+				Labels struct {
+					Nodes []struct {
+						ID          string `json:"id"`
+						Name        string `json:"name"`
+						Description string `json:"description"`
+						Color       string `json:"color"`
+						IsDefault   bool   `json:"isDefault"`
+						URL         string `json:"url"`
+					} `json:"nodes"`
+				} `json:"labels"`
+			} `json:"labelable"`
+		} `json:"addLabelsToLabelable"`
+	}{}
+	if err := api.client.mutate(ctx, addLabelsMutation, map[string]interface{}{"input": input}, &result); err != nil {
+		return nil, fmt.Errorf("add labels to #%d: %w", issueNumber, err)
+	}
+
+	labelsData := github.LabelsRequestData{}
+	for _, l := range result.AddLabelsToLabelable.Labelable.Labels.Nodes {
+		labelsData = append(labelsData, github.LabelRequestData{
+			NodeID:      l.ID,
+			URL:         l.URL,
+			Name:        l.Name,
+			Description: l.Description,
+			Color:       l.Color,
+			Default:     l.IsDefault,
+		})
+	}
+	return &labelsData, nil
+}
+
+// FindOpenUpgradePullRequest - find the open upgrade PR in the quorum repo for a geth tag, nil if there is none
+func (api *GraphQLGithub) FindOpenUpgradePullRequest(ctx context.Context, targetTag string) (*github.PullRequestData, error) {
+	title := github.UpgradePullRequestTitle(targetTag)
+
+	prs, err := api.GetOpenPullRequests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range prs {
+		if pr.Title == title {
+			return &pr, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetOpenPullRequests - get all the open PRs in the quorum repo, whatever their title
+func (api *GraphQLGithub) GetOpenPullRequests(ctx context.Context) ([]github.PullRequestData, error) {
+	openPrs := make([]github.PullRequestData, 0)
+	variables := api.quorumVariables()
+	for {
+		data := struct {
+			Repository struct {
+				PullRequests struct {
+					Nodes    []pullRequestNode `json:"nodes"`
+					PageInfo pageInfo          `json:"pageInfo"`
+				} `json:"pullRequests"`
+			} `json:"repository"`
+		}{}
+		if err := api.client.query(ctx, openPullRequestsQuery, variables, &data); err != nil {
+			return nil, fmt.Errorf("get open PRs: %w", err)
+		}
+		for _, pr := range data.Repository.PullRequests.Nodes {
+			openPrs = append(openPrs, pr.toPullRequestData())
+		}
+		if !data.Repository.PullRequests.PageInfo.HasNextPage {
+			return openPrs, nil
+		}
+		variables["after"] = data.Repository.PullRequests.PageInfo.EndCursor
+	}
+}
+
+// getComparedPullRequests - merged geth PRs associated with the commits between the two tags, in the order of the commits
+func (api *GraphQLGithub) getComparedPullRequests(ctx context.Context, base string, target string) ([]github.PullRequestData, error) {
+	uniquePrs := make(map[int]bool)
+	result := make([]github.PullRequestData, 0)
+
+	after := ""
+	for {
+		variables := api.gethVariables(after)
+		variables["base"] = "refs/tags/" + base
+		variables["head"] = "refs/tags/" + target
+
+		data := struct {
+			Repository struct {
+				Ref *struct {
+					Compare struct {
+						Commits struct {
+							Nodes []struct {
+								Oid                    string `json:"oid"`
+								AssociatedPullRequests struct {
+									Nodes []pullRequestNode `json:"nodes"`
+								} `json:"associatedPullRequests"`
+							} `json:"nodes"`
+							PageInfo pageInfo `json:"pageInfo"`
+						} `json:"commits"`
+					} `json:"compare"`
+				} `json:"ref"`
+			} `json:"repository"`
+		}{}
+		if err := api.client.query(ctx, compareQuery, variables, &data); err != nil {
+			return nil, fmt.Errorf("compare %s...%s: %w", base, target, err)
+		}
+		if data.Repository.Ref == nil {
+			return nil, fmt.Errorf("compare %s...%s: tag %s: %w", base, target, base, github.ErrNotFound)
+		}
+
+		commits := data.Repository.Ref.Compare.Commits
+		for _, commit := range commits.Nodes {
+			for _, pr := range commit.AssociatedPullRequests.Nodes {
+				// commits are also associated with PRs of forks and with unmerged PRs
+				if !pr.Merged || !strings.EqualFold(pr.BaseRepository.NameWithOwner, api.config.GethRepoName) || uniquePrs[pr.Number] {
+					continue
+				}
+				uniquePrs[pr.Number] = true
+				result = append(result, pr.toPullRequestData())
+			}
+		}
+		if !commits.PageInfo.HasNextPage {
+			return result, nil
+		}
+		after = commits.PageInfo.EndCursor
+	}
+}
+
+// getPullRequestsFiles - fetch the files of the PRs by batches of filesBatchSize PRs per query
+func (api *GraphQLGithub) getPullRequestsFiles(ctx context.Context, prsData []github.PullRequestData) ([]github.PullRequest, error) {
+	pullRequests := make([]github.PullRequest, len(prsData))
+	batchCount := (len(prsData) + filesBatchSize - 1) / filesBatchSize
+
+	err := workerpool.Run(ctx, api.config.GithubWorkers, batchCount, func(ctx context.Context, b int) error {
+		start := b * filesBatchSize
+		end := start + filesBatchSize
+		if end > len(prsData) {
+			end = len(prsData)
+		}
+
+		data := struct {
+			Repository map[string]struct {
+				Files filesConnection `json:"files"`
+			} `json:"repository"`
+		}{}
+		if err := api.client.query(ctx, pullRequestsFilesQuery(prsData[start:end]), api.gethVariables(""), &data); err != nil {
+			return fmt.Errorf("get files of PRs: %w", err)
+		}
+
+		for i := start; i < end; i++ {
+			firstPage := data.Repository[pullRequestAlias(i-start)].Files
+			files, err := api.getRemainingFiles(ctx, prsData[i].Number, firstPage)
+			if err != nil {
+				return err
+			}
+			pullRequests[i] = github.PullRequest{Data: prsData[i], Files: files}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pullRequests, nil
+}
+
+// getRemainingFiles - files of the first page, followed by the files of the next pages of a PR having more than 100 files
+func (api *GraphQLGithub) getRemainingFiles(ctx context.Context, number int, firstPage filesConnection) ([]github.File, error) {
+	files := make([]github.File, 0, len(firstPage.Nodes))
+	page := firstPage
+	for {
+		for _, f := range page.Nodes {
+			files = append(files, f.toFile())
+		}
+		if !page.PageInfo.HasNextPage {
+			return files, nil
+		}
+
+		variables := api.gethVariables(page.PageInfo.EndCursor)
+		variables["number"] = number
+		data := struct {
+			Repository struct {
+				PullRequest struct {
+					Files filesConnection `json:"files"`
+				} `json:"pullRequest"`
+			} `json:"repository"`
+		}{}
+		if err := api.client.query(ctx, pullRequestFilesQuery, variables, &data); err != nil {
+			return nil, fmt.Errorf("get files of PR #%d: %w", number, err)
+		}
+		page = data.Repository.PullRequest.Files
+	}
+}
+
+func (api *GraphQLGithub) gethVariables(after string) map[string]interface{} {
+	variables := map[string]interface{}{"owner": api.gethOwner, "name": api.gethName}
+	if after != "" {
+		variables["after"] = after
+	}
+	return variables
+}
+
+func (api *GraphQLGithub) quorumVariables() map[string]interface{} {
+	return map[string]interface{}{"owner": api.quorumOwner, "name": api.quorumName}
+}
+
+// pullRequestsFilesQuery - query of the first 100 files of each PR, aliased pr0, pr1... in the order of prsData
+func pullRequestsFilesQuery(prsData []github.PullRequestData) string {
+	builder := strings.Builder{}
+	builder.WriteString("query($owner: String!, $name: String!) {\n\trepository(owner: $owner, name: $name) {\n")
+	for i, pr := range prsData {
+		fmt.Fprintf(&builder, "\t\t%s: pullRequest(number: %d) { files(first: 100) { nodes { %s } pageInfo { hasNextPage endCursor } } }\n",
+			pullRequestAlias(i), pr.Number, fileFields)
+	}
+	builder.WriteString("\t}\n}")
+	return builder.String()
+}
+
+func pullRequestAlias(i int) string {
+	return fmt.Sprintf("pr%d", i)
+}
+
+// aggregateFiles - files changed by all the PRs, with the sum of their changes.
+// A file keeps the status of the first PR that changed it, unless a later PR removed it.
+func aggregateFiles(pullRequests []github.PullRequest) []github.File {
+	filesByName := make(map[string]*github.File)
+	names := make([]string, 0)
+	for _, pr := range pullRequests {
+		for _, f := range pr.Files {
+			aggregated, ok := filesByName[f.Filename]
+			if !ok {
+				file := f
+				filesByName[f.Filename] = &file
+				names = append(names, f.Filename)
+				continue
+			}
+			aggregated.Additions += f.Additions
+			aggregated.Deletions += f.Deletions
+			aggregated.Changes += f.Changes
+			if f.Status == "removed" {
+				aggregated.Status = f.Status
+			}
+		}
+	}
+
+	files := make([]github.File, len(names))
+	for i, name := range names {
+		files[i] = *filesByName[name]
+	}
+	return files
+}
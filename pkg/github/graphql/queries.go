@@ -0,0 +1,209 @@
+package graphql
+
+import (
+	"strings"
+
+	"upgradebot/pkg/github"
+)
+
+const releaseFields = `name tagName description isPrerelease isDraft publishedAt`
+
+const pullRequestFields = `number url title body closedAt merged
+	mergeCommit { oid }
+	comments { totalCount }
+	author { login }
+	labels(first: 20) { nodes { name } }
+	headRefName
+	headRepositoryOwner { login }
+	baseRepository { nameWithOwner }`
+
+const fileFields = `path additions deletions changeType`
+
+const releasesQuery = `query($owner: String!, $name: String!, $after: String) {
+	repository(owner: $owner, name: $name) {
+		releases(first: 100, after: $after, orderBy: {field: CREATED_AT, direction: DESC}) {
+			nodes { ` + releaseFields + ` }
+			pageInfo { hasNextPage endCursor }
+		}
+	}
+}`
+
+const releaseQuery = `query($owner: String!, $name: String!, $tag: String!) {
+	repository(owner: $owner, name: $name) {
+		release(tagName: $tag) { ` + releaseFields + ` }
+	}
+}`
+
+// compareQuery - commits between two refs, with the PRs they are associated with
+const compareQuery = `query($owner: String!, $name: String!, $base: String!, $head: String!, $after: String) {
+	repository(owner: $owner, name: $name) {
+		ref(qualifiedName: $base) {
+			compare(headRef: $head) {
+				commits(first: 100, after: $after) {
+					nodes {
+						oid
+						associatedPullRequests(first: 5) { nodes { ` + pullRequestFields + ` } }
+					}
+					pageInfo { hasNextPage endCursor }
+				}
+			}
+		}
+	}
+}`
+
+// pullRequestFilesQuery - following pages of the files of a PR having more than 100 files
+const pullRequestFilesQuery = `query($owner: String!, $name: String!, $number: Int!, $after: String) {
+	repository(owner: $owner, name: $name) {
+		pullRequest(number: $number) {
+			files(first: 100, after: $after) {
+				nodes { ` + fileFields + ` }
+				pageInfo { hasNextPage endCursor }
+			}
+		}
+	}
+}`
+
+const openPullRequestsQuery = `query($owner: String!, $name: String!, $after: String) {
+	repository(owner: $owner, name: $name) {
+		pullRequests(states: OPEN, first: 100, after: $after) {
+			nodes { ` + pullRequestFields + ` }
+			pageInfo { hasNextPage endCursor }
+		}
+	}
+}`
+
+const repositoryIDQuery = `query($owner: String!, $name: String!) {
+	repository(owner: $owner, name: $name) { id }
+}`
+
+const createPullRequestMutation = `mutation($input: CreatePullRequestInput!) {
+	createPullRequest(input: $input) {
+		pullRequest { ` + pullRequestFields + ` }
+	}
+}`
+
+const labelsQuery = `query($owner: String!, $name: String!, $number: Int!, $labels: String!) {
+	repository(owner: $owner, name: $name) {
+		pullRequest(number: $number) { id }
+		labels(first: 100, query: $labels) { nodes { id name } }
+	}
+}`
+
+const addLabelsMutation = `mutation($input: AddLabelsToLabelableInput!) {
+	addLabelsToLabelable(input: $input) {
+		labelable {
+			... on PullRequest {
+				labels(first: 100) { nodes { id name description color isDefault url } }
+			}
+		}
+	}
+}`
+
+type pageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type releaseNode struct {
+	Name         string `json:"name"`
+	TagName      string `json:"tagName"`
+	Description  string `json:"description"`
+	IsPrerelease bool   `json:"isPrerelease"`
+	IsDraft      bool   `json:"isDraft"`
+	PublishedAt  string `json:"publishedAt"`
+}
+
+func (r releaseNode) toReleaseData() github.ReleaseData {
+	return github.ReleaseData{
+		Name:        r.Name,
+		Body:        r.Description,
+		Prerelease:  r.IsPrerelease,
+		Draft:       r.IsDraft,
+		Tag:         r.TagName,
+		PublishedAt: r.PublishedAt,
+	}
+}
+
+type pullRequestNode struct {
+	Number   int    `json:"number"`
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+	ClosedAt string `json:"closedAt"`
+	Merged   bool   `json:"merged"`
+	// MergeCommit - null for a PR that is not merged
+	MergeCommit *struct {
+		Oid string `json:"oid"`
+	} `json:"mergeCommit"`
+	Comments struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"comments"`
+	Author struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Labels struct {
+		Nodes []struct {
+			Name string `json:"name"`
+		} `json:"nodes"`
+	} `json:"labels"`
+	HeadRefName         string `json:"headRefName"`
+	HeadRepositoryOwner struct {
+		Login string `json:"login"`
+	} `json:"headRepositoryOwner"`
+	BaseRepository struct {
+		NameWithOwner string `json:"nameWithOwner"`
+	} `json:"baseRepository"`
+}
+
+func (pr pullRequestNode) toPullRequestData() github.PullRequestData {
+	labels := make([]github.Label, len(pr.Labels.Nodes))
+	for i, l := range pr.Labels.Nodes {
+		labels[i] = github.Label{Name: l.Name}
+	}
+	head := github.PullRequestHead{Ref: pr.HeadRefName}
+	if pr.HeadRepositoryOwner.Login != "" {
+		head.Label = pr.HeadRepositoryOwner.Login + ":" + pr.HeadRefName
+	}
+	data := github.PullRequestData{
+		Number:   pr.Number,
+		HtmlUrl:  pr.URL,
+		Title:    pr.Title,
+		Body:     pr.Body,
+		Comments: pr.Comments.TotalCount,
+		ClosedAt: pr.ClosedAt,
+		Head:     head,
+		User:     github.User{Login: pr.Author.Login},
+		Labels:   labels,
+	}
+	if pr.MergeCommit != nil {
+		data.MergeCommitSha = pr.MergeCommit.Oid
+	}
+	return data
+}
+
+type fileNode struct {
+	Path       string `json:"path"`
+	Additions  int    `json:"additions"`
+	Deletions  int    `json:"deletions"`
+	ChangeType string `json:"changeType"`
+}
+
+// toFile - file with the status and changes of the REST API
+func (f fileNode) toFile() github.File {
+	status := strings.ToLower(f.ChangeType)
+	if f.ChangeType == "DELETED" {
+		status = "removed"
+	}
+	return github.File{
+		Status:    status,
+		Filename:  f.Path,
+		Additions: f.Additions,
+		Deletions: f.Deletions,
+		Changes:   f.Additions + f.Deletions,
+	}
+}
+
+type filesConnection struct {
+	Nodes    []fileNode `json:"nodes"`
+	PageInfo pageInfo   `json:"pageInfo"`
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// cacheEntry - cached response of a GET request, revalidated with If-None-Match/If-Modified-Since
+type cacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"lastModified"`
+	Link         string `json:"link"`
+	Body         []byte `json:"body"`
+}
+
+// diskCache - cache of the GET responses on disk, one JSON file per URL
+type diskCache struct {
+	folder string
+}
+
+func newDiskCache(folder string) *diskCache {
+	if folder == "" {
+		return nil
+	}
+	return &diskCache{folder: folder}
+}
+
+// get - cached response of the url, nil if there is none
+func (c *diskCache) get(url string) *cacheEntry {
+	content, err := ioutil.ReadFile(c.path(url))
+	if err != nil {
+		return nil
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(content, entry); err != nil || entry.URL != url {
+		return nil
+	}
+	return entry
+}
+
+// put - cache the response of the url if it can be revalidated, a failure only disables the cache for this url
+func (c *diskCache) put(url string, header http.Header, body []byte) {
+	entry := cacheEntry{
+		URL:          url,
+		ETag:         header.Get("ETag"),
+		LastModified: header.Get("Last-Modified"),
+		Link:         header.Get("Link"),
+		Body:         body,
+	}
+	if entry.ETag == "" && entry.LastModified == "" {
+		return
+	}
+
+	if err := c.write(url, entry); err != nil {
+		log.Printf("Failed to cache the response: %v\n", err)
+	}
+}
+
+// write - write the entry to a temporary file renamed to the cache file, so that a cache file is never partially written
+func (c *diskCache) write(url string, entry cacheEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.folder, 0755); err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(c.folder, "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(content); err != nil {
+		tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), c.path(url))
+}
+
+func (c *diskCache) path(url string) string {
+	hash := sha256.Sum256([]byte(url))
+	return filepath.Join(c.folder, hex.EncodeToString(hash[:])+".json")
+}
+
+// setConditionalHeaders - ask GitHub to answer 304 Not Modified if the cached response is still valid
+func (entry *cacheEntry) setConditionalHeaders(req *http.Request) {
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// header - headers of the cached response needed by the callers
+func (entry *cacheEntry) header() http.Header {
+	header := http.Header{}
+	if entry.Link != "" {
+		header.Set("Link", entry.Link)
+	}
+	return header
+}
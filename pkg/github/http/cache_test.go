@@ -0,0 +1,94 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"upgradebot/config"
+)
+
+func TestCacheRevalidation(t *testing.T) {
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		etag := `"page-` + page + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		if page == "1" {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/items?per_page=100&page=2>; rel="next"`, r.Host))
+		}
+		fmt.Fprintf(w, "[%s]", page)
+	}))
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.HTTPCacheFolder = t.TempDir()
+	getAllPages := func() []string {
+		pages := make([]string, 0)
+		err := NewHTTPClient(cfg).DoGetAllPages(context.Background(), server.URL+"/items", func(body []byte) error {
+			pages = append(pages, string(body))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("DoGetAllPages: %v", err)
+		}
+		return pages
+	}
+
+	want := []string{"[1]", "[2]"}
+	if pages := getAllPages(); !reflect.DeepEqual(pages, want) {
+		t.Fatalf("pages = %v, want %v", pages, want)
+	}
+	if notModified != 0 {
+		t.Fatalf("notModified = %d before anything is cached", notModified)
+	}
+
+	// the cached bodies are returned, and the cached Link header still leads to the second page
+	if pages := getAllPages(); !reflect.DeepEqual(pages, want) {
+		t.Errorf("cached pages = %v, want %v", pages, want)
+	}
+	if notModified != 2 {
+		t.Errorf("notModified = %d, want 2", notModified)
+	}
+}
+
+func TestCacheSkipsResponsesWithoutValidator(t *testing.T) {
+	cache := newDiskCache(t.TempDir())
+	cache.put("https://api.github.com/a", http.Header{}, []byte("a"))
+	if entry := cache.get("https://api.github.com/a"); entry != nil {
+		t.Errorf("get = %+v, want no entry", entry)
+	}
+
+	header := http.Header{}
+	header.Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	cache.put("https://api.github.com/b", header, []byte("b"))
+	entry := cache.get("https://api.github.com/b")
+	if entry == nil || string(entry.Body) != "b" {
+		t.Fatalf("get = %+v, want the body b", entry)
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://api.github.com/b", nil)
+	entry.setConditionalHeaders(req)
+	if got := req.Header.Get("If-Modified-Since"); got != "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Errorf("If-Modified-Since = %q", got)
+	}
+	if got := req.Header.Get("If-None-Match"); got != "" {
+		t.Errorf("If-None-Match = %q, want none", got)
+	}
+}
+
+func TestNoCacheWithoutFolder(t *testing.T) {
+	if cache := newDiskCache(""); cache != nil {
+		t.Errorf("newDiskCache(\"\") = %+v, want nil", cache)
+	}
+}
@@ -1,50 +1,122 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"upgradebot/config"
+	"upgradebot/pkg/github"
+	"upgradebot/pkg/redact"
 )
 
 type HTTPClient struct {
-	httpClient *http.Client
-	config     *config.Config
+	httpClient  *http.Client
+	config      *config.Config
+	redactor    *redact.Redactor
+	rateLimiter *rateLimiter
+	cache       *diskCache
 }
 
-func newHttpAdapter(config *config.Config) *HTTPClient {
+// NewHTTPClient - client of the GitHub API authenticated with the configured credentials
+func NewHTTPClient(config *config.Config) *HTTPClient {
 	client := &http.Client{}
 	return &HTTPClient{
-		httpClient: client,
-		config:     config,
+		httpClient:  client,
+		config:      config,
+		redactor:    redact.New(config.GithubUserToken),
+		rateLimiter: &rateLimiter{},
+		cache:       newDiskCache(config.HTTPCacheFolder),
 	}
 }
 
-func (adapter *HTTPClient) DoPost(url string, body io.Reader) ([]byte, error) {
-	req, err := http.NewRequest("POST", url, body)
+func (adapter *HTTPClient) DoPost(ctx context.Context, url string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := adapter.do(req)
+	resp, err := adapter.do(req, false)
 	if err != nil {
 		return nil, err
 	}
 	return adapter.deserialize(resp)
 }
 
-func (adapter *HTTPClient) DoGet(url string) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// DoQuery - POST a request without side effects, e.g. a GraphQL query, retried on network errors and 5xx like a GET.
+// The header of the response is returned with its body, e.g. for RetryRateLimited.
+func (adapter *HTTPClient) DoQuery(ctx context.Context, url string, body io.Reader) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	resp, err := adapter.do(req, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	respBody, err := adapter.deserialize(resp)
+	if err != nil {
+		return nil, nil, err
 	}
+	return respBody, resp.Header, nil
+}
+
+func (adapter *HTTPClient) DoGet(ctx context.Context, url string) ([]byte, error) {
+	body, _, err := adapter.doGet(ctx, url)
+	return body, err
+}
 
-	resp, err := adapter.do(req)
+// DoGetAllPages - GET the url and every following page given by the `Link: rel="next"` header, calling onPage with the body of each page
+func (adapter *HTTPClient) DoGetAllPages(ctx context.Context, url string, onPage func(body []byte) error) error {
+	url = withMaxPerPage(url)
+	for url != "" {
+		body, header, err := adapter.doGet(ctx, url)
+		if err != nil {
+			return err
+		}
+		if err := onPage(body); err != nil {
+			return err
+		}
+		url = nextPageURL(header)
+	}
+	return nil
+}
+
+// doGet - GET the url, revalidating the cached response if the cache is enabled: a 304 does not count against the rate limit
+func (adapter *HTTPClient) doGet(ctx context.Context, url string) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return adapter.deserialize(resp)
+
+	var cached *cacheEntry
+	if adapter.cache != nil {
+		cached = adapter.cache.get(url)
+		if cached != nil {
+			cached.setConditionalHeaders(req)
+		}
+	}
+
+	resp, err := adapter.do(req, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		discard(resp)
+		return cached.Body, cached.header(), nil
+	}
+	body, err := adapter.deserialize(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if adapter.cache != nil {
+		adapter.cache.put(url, resp.Header, body)
+	}
+	return body, resp.Header, nil
 }
 
 func (adapter *HTTPClient) deserialize(resp *http.Response) ([]byte, error) {
@@ -54,24 +126,72 @@ func (adapter *HTTPClient) deserialize(resp *http.Response) ([]byte, error) {
 		return nil, err
 	}
 
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, adapter.newAPIError(resp, body)
+	}
+
 	return body, nil
 }
 
-func (adapter *HTTPClient) do(req *http.Request) (*http.Response, error) {
-	log.Printf("%s %s\n", req.Method, req.URL)
+// newAPIError - APIError of a non-2xx response, with the message and errors of the body if it is JSON
+func (adapter *HTTPClient) newAPIError(resp *http.Response, body []byte) *github.APIError {
+	apiErr := &github.APIError{}
+	if json.Unmarshal(body, apiErr) != nil {
+		apiErr = &github.APIError{Message: strings.TrimSpace(string(body))}
+	}
+	apiErr.Method = resp.Request.Method
+	apiErr.URL = adapter.redactor.String(resp.Request.URL.String())
+	apiErr.StatusCode = resp.StatusCode
+	apiErr.RateLimited = isRateLimited(resp) || (resp.StatusCode == http.StatusForbidden && isRateLimitMessage(apiErr.Message))
+	return apiErr
+}
+
+// isRateLimited - GitHub answers 429, or 403 with no remaining requests or a Retry-After, when a rate limit is exceeded
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return resp.StatusCode == http.StatusForbidden &&
+		(resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != "")
+}
+
+// do - send the request, waiting for the rate limit reset and retrying with backoff when rate-limited or on transient errors,
+// the latter only if the request is idempotent
+func (adapter *HTTPClient) do(req *http.Request, idempotent bool) (*http.Response, error) {
+	url := adapter.redactor.String(req.URL.String())
 	req.SetBasicAuth(adapter.config.GithubUsername, adapter.config.GithubUserToken)
 	req.Header.Add("Accept", "application/vnd.github.v3+json")
-	resp, err := adapter.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	// For DEBUG. print HTTP headers
-	//for name, values := range resp.Header {
-	//	// Loop over all values for the name.
-	//	for _, value := range values {
-	//		fmt.Println(name, value)
-	//	}
-	//}
-	return resp, nil
 
+	for attempt := 0; ; attempt++ {
+		if err := adapter.rateLimiter.wait(req.Context()); err != nil {
+			return nil, err
+		}
+
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := adapter.httpClient.Do(req)
+		if err == nil {
+			logRateLimit(req, resp, url)
+		}
+
+		delay, retry := adapter.retryDelay(req, resp, err, attempt, idempotent)
+		if !retry {
+			return resp, err
+		}
+		if err != nil {
+			log.Printf("%s %s: %v, retry #%d in %s\n", req.Method, url, adapter.redactor.String(err.Error()), attempt+1, delay.Round(time.Millisecond))
+		} else {
+			log.Printf("%s %s: retry #%d in %s\n", req.Method, url, attempt+1, delay.Round(time.Millisecond))
+			discard(resp)
+		}
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
 }
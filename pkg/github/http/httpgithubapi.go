@@ -2,17 +2,19 @@ package http
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"math"
 	"strings"
 
 	"upgradebot/config"
 	"upgradebot/pkg/github"
+	"upgradebot/pkg/workerpool"
 )
 
-const PullRequestTitleFormat = "[Upgrade] Go-Ethereum release %s"
+// searchBatchSize - number of commit SHAs per search query, to stay under the maximum length of a search query
+const searchBatchSize = 28
 
 type HTTPGithub struct {
 	httpAdapter *HTTPClient
@@ -20,71 +22,76 @@ type HTTPGithub struct {
 }
 
 func NewGithub(config *config.Config) github.Github {
-	client := newHttpAdapter(config)
+	client := NewHTTPClient(config)
 	return &HTTPGithub{
 		httpAdapter: client,
 		config:      config,
 	}
 }
 
-// GetNextReleaseFrom - get the next go-ethereum release after a specific version/tag
-func (api *HTTPGithub) GetNextReleaseFrom(baseTag string) github.ReleaseData {
-	releases := api.GetAllGethReleases()
-	releaseIndex := 0
-
-	for i, r := range releases {
-		if r.Tag == baseTag {
-			releaseIndex = i - 1
-		}
-	}
-
-	if releaseIndex < 0 {
-		log.Fatal("Next release error notn found")
+// GetUpgradeReleases - get the go-ethereum releases from a specific version/tag to the target tag, or to the release chosen by the upgrade policy if no target is given
+func (api *HTTPGithub) GetUpgradeReleases(ctx context.Context, baseTag string, targetTag string) (github.UpgradeReleases, error) {
+	releases, err := api.GetAllGethReleases(ctx)
+	if err != nil {
+		return github.UpgradeReleases{}, err
 	}
-
-	return releases[releaseIndex]
+	return github.SelectUpgrade(releases, baseTag, targetTag, github.UpgradePolicy(api.config.UpgradePolicy), api.config.IncludePrereleases)
 }
 
 // GetAllGethReleases - get all go-ethereum releases
-func (api *HTTPGithub) GetAllGethReleases() []github.ReleaseData {
-	body, err := api.httpAdapter.DoGet(api.config.GethGithubAPIUrl + "/releases")
+func (api *HTTPGithub) GetAllGethReleases(ctx context.Context) ([]github.ReleaseData, error) {
+	data := make([]github.ReleaseData, 0)
+	err := api.httpAdapter.DoGetAllPages(ctx, api.config.GethGithubAPIUrl+"/releases", func(body []byte) error {
+		var page []github.ReleaseData
+		if err := parseJson(body, &page); err != nil {
+			return err
+		}
+		data = append(data, page...)
+		return nil
+	})
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("get geth releases: %w", err)
 	}
-	var data []github.ReleaseData
-	parseJson(body, &data)
-	return data
+	return data, nil
 }
 
 // GetGethReleaseData - get go-ethereum release data based on a tag
-func (api *HTTPGithub) GetGethReleaseData(tag string) github.ReleaseData {
+func (api *HTTPGithub) GetGethReleaseData(ctx context.Context, tag string) (github.ReleaseData, error) {
 	url := fmt.Sprintf("%s/releases/tags/%s", api.config.GethGithubAPIUrl, tag)
 
-	body, err := api.httpAdapter.DoGet(url)
+	body, err := api.httpAdapter.DoGet(ctx, url)
 	if err != nil {
-		log.Fatal(err)
+		return github.ReleaseData{}, fmt.Errorf("get geth release %s: %w", tag, err)
 	}
 	data := github.ReleaseData{}
-	parseJson(body, &data)
+	if err := parseJson(body, &data); err != nil {
+		return github.ReleaseData{}, fmt.Errorf("get geth release %s: %w", tag, err)
+	}
 
-	return data
+	return data, nil
 }
 
 // GetGethTagComparison - compare two geth tags and extract PR merged and files changed
-func (api *HTTPGithub) GetGethTagComparison(base string, target string) github.TagCompare {
-	commitChanges := api.getCommitChanges(base, target)
-	prsData := api.getPullRequests(commitChanges)
-	return github.TagCompare{PullRequests: prsData, Files: commitChanges.Files}
+func (api *HTTPGithub) GetGethTagComparison(ctx context.Context, base string, target string) (github.TagCompare, error) {
+	commitChanges, err := api.getCommitChanges(ctx, base, target)
+	if err != nil {
+		return github.TagCompare{}, err
+	}
+	prsData, err := api.getPullRequests(ctx, commitChanges)
+	if err != nil {
+		return github.TagCompare{}, err
+	}
+	return github.TagCompare{PullRequests: prsData, Files: commitChanges.Files}, nil
 }
 
 // CreateQuorumPullRequest - create PR in the quorum repo
-func (api *HTTPGithub) CreateQuorumPullRequest(branchName string, data github.ReleaseData, prBody string) (*github.PullRequestData, error) {
-	title := fmt.Sprintf(PullRequestTitleFormat, data.Tag)
+func (api *HTTPGithub) CreateQuorumPullRequest(ctx context.Context, branchName string, data github.ReleaseData, prBody string) (*github.PullRequestData, error) {
+	title := github.UpgradePullRequestTitle(data.Tag)
 	createPrBody := github.CreatePullRequest{
 		Title: title,
 		Body:  prBody,
-		Base:  "master",
-		Head:  "quorumbot:" + branchName, // created from QuorumBot fork
+		Base:  api.config.QuorumBaseBranch,
+		Head:  api.config.QuorumBotOwner + ":" + branchName, // created from QuorumBot fork
 		Draft: true,
 	}
 
@@ -93,88 +100,147 @@ func (api *HTTPGithub) CreateQuorumPullRequest(branchName string, data github.Re
 		return nil, fmt.Errorf("json reader: %w", err)
 	}
 
-	response, err := api.httpAdapter.DoPost(api.config.QuorumAPIUrl+"/pulls", jsonReader)
+	response, err := api.httpAdapter.DoPost(ctx, api.config.QuorumAPIUrl+"/pulls", jsonReader)
 	if err != nil {
 		return nil, fmt.Errorf("do post: %w", err)
 	}
 
 	result := &github.PullRequestData{}
-	parseJson(response, result)
+	if err := parseJson(response, result); err != nil {
+		return nil, fmt.Errorf("create PR: %w", err)
+	}
 
 	return result, nil
 }
 
 // AddLabelsToIssue - adds some labels to the issue
-func (api *HTTPGithub) AddLabelsToIssue(issueNumber int, labels ...string) *github.LabelsRequestData {
+func (api *HTTPGithub) AddLabelsToIssue(ctx context.Context, issueNumber int, labels ...string) (*github.LabelsRequestData, error) {
 	// POST {{baseUrl}}/repos/:owner/:repo/issues/:issue_number/labels a JSON body labels -> array of strings
 	labelsBody := github.LabelsRequest{Labels: labels}
-	jsonReader, readerErr := newReader(labelsBody)
-	if readerErr != nil {
-		log.Fatal(readerErr)
-		return nil
+	jsonReader, err := newReader(labelsBody)
+	if err != nil {
+		return nil, fmt.Errorf("json reader: %w", err)
 	}
-	response, err := api.httpAdapter.DoPost(fmt.Sprintf("%s/issues/%d/labels", api.config.QuorumAPIUrl, issueNumber), jsonReader)
+	response, err := api.httpAdapter.DoPost(ctx, fmt.Sprintf("%s/issues/%d/labels", api.config.QuorumAPIUrl, issueNumber), jsonReader)
 	if err != nil {
-		log.Fatal(err)
-		return nil
+		return nil, fmt.Errorf("add labels to #%d: %w", issueNumber, err)
 	}
 
 	result := &github.LabelsRequestData{}
-	parseJson(response, result)
+	if err := parseJson(response, result); err != nil {
+		return nil, fmt.Errorf("add labels to #%d: %w", issueNumber, err)
+	}
 
-	return result
+	return result, nil
 }
 
-func (api *HTTPGithub) FindOpenUpgradePullRequest(targetTag string) *github.PullRequestData {
-	title := fmt.Sprintf(PullRequestTitleFormat, targetTag)
-
-	response, _ := api.httpAdapter.DoGet(api.config.QuorumAPIUrl + "/pulls?state=open&per_page=100")
+// FindOpenUpgradePullRequest - find the open upgrade PR in the quorum repo for a geth tag, nil if there is none
+func (api *HTTPGithub) FindOpenUpgradePullRequest(ctx context.Context, targetTag string) (*github.PullRequestData, error) {
+	title := github.UpgradePullRequestTitle(targetTag)
 
-	var result []github.PullRequestData
-	parseJson(response, &result)
-
-	for _, pr := range result {
+	prs, err := api.GetOpenPullRequests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range prs {
 		if pr.Title == title {
-			return &pr
+			return &pr, nil
 		}
 	}
-	return nil
+	return nil, nil
 }
 
-func (api *HTTPGithub) getPullRequests(commitChanges github.CommitChanges) []github.PullRequest {
-	prsData := api.getPullRequestDataFromCommits(commitChanges)
+// GetOpenPullRequests - get all the open PRs in the quorum repo, whatever their title
+func (api *HTTPGithub) GetOpenPullRequests(ctx context.Context) ([]github.PullRequestData, error) {
+	openPrs := make([]github.PullRequestData, 0)
+	err := api.httpAdapter.DoGetAllPages(ctx, api.config.QuorumAPIUrl+"/pulls?state=open", func(body []byte) error {
+		var page []github.PullRequestData
+		if err := parseJson(body, &page); err != nil {
+			return err
+		}
+		openPrs = append(openPrs, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get open PRs: %w", err)
+	}
+	return openPrs, nil
+}
+
+func (api *HTTPGithub) getPullRequests(ctx context.Context, commitChanges github.CommitChanges) ([]github.PullRequest, error) {
+	prsData, err := api.getPullRequestDataFromCommits(ctx, commitChanges)
+	if err != nil {
+		return nil, err
+	}
+
+	// the search results have no merge commit
+	for i := range prsData {
+		if prsData[i].MergeCommitSha == "" {
+			prsData[i].MergeCommitSha = commitChanges.MergeCommitSha(prsData[i].Number)
+		}
+	}
 
 	pullRequests := make([]github.PullRequest, len(prsData))
 
-	for i, prData := range prsData {
-		files := api.getPullRequestFiles(prData)
-		pr := github.PullRequest{Data: prData, Files: files}
-		pullRequests[i] = pr
+	err = workerpool.Run(ctx, api.config.GithubWorkers, len(prsData), func(ctx context.Context, i int) error {
+		files, err := api.getPullRequestFiles(ctx, prsData[i])
+		if err != nil {
+			return err
+		}
+		pullRequests[i] = github.PullRequest{Data: prsData[i], Files: files}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return pullRequests
+	return pullRequests, nil
 }
 
-func (api *HTTPGithub) getPullRequestFiles(prData github.PullRequestData) []github.File {
+func (api *HTTPGithub) getPullRequestFiles(ctx context.Context, prData github.PullRequestData) ([]github.File, error) {
 	url := fmt.Sprintf("%s/pulls/%d/files", api.config.GethGithubAPIUrl, prData.Number)
 
-	body, _ := api.httpAdapter.DoGet(url)
-	var prFiles []github.File
-	parseJson(body, &prFiles)
-	return prFiles
+	prFiles := make([]github.File, 0)
+	err := api.httpAdapter.DoGetAllPages(ctx, url, func(body []byte) error {
+		var page []github.File
+		if err := parseJson(body, &page); err != nil {
+			return err
+		}
+		prFiles = append(prFiles, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get files of PR #%d: %w", prData.Number, err)
+	}
+	return prFiles, nil
 }
 
-func (api *HTTPGithub) getPullRequestDataFromCommits(commitChanges github.CommitChanges) []github.PullRequestData {
+func (api *HTTPGithub) getPullRequestDataFromCommits(ctx context.Context, commitChanges github.CommitChanges) ([]github.PullRequestData, error) {
 	length := len(commitChanges.Commits)
-	requestDataArray := make([]github.PullRequestData, 0)
 
 	shas := make([]string, length)
 	for i, c := range commitChanges.Commits {
 		shas[i] = c.Sha[0:7]
 	}
 
-	for i := 0; i < length; i = i + 28 {
-		end := math.Min(28, float64(length-i))
-		requests := api.getPullRequestsData(shas[i : i+int(end)])
+	// search the PRs by batches of 28 commits, the results are kept in the order of the batches
+	batchCount := int(math.Ceil(float64(length) / searchBatchSize))
+	batches := make([][]github.PullRequestData, batchCount)
+	err := workerpool.Run(ctx, api.config.GithubWorkers, batchCount, func(ctx context.Context, b int) error {
+		start := b * searchBatchSize
+		end := int(math.Min(float64(start+searchBatchSize), float64(length)))
+		requests, err := api.getPullRequestsData(ctx, shas[start:end])
+		if err != nil {
+			return err
+		}
+		batches[b] = requests
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	requestDataArray := make([]github.PullRequestData, 0)
+	for _, requests := range batches {
 		requestDataArray = append(requestDataArray, requests...)
 	}
 
@@ -190,41 +256,60 @@ func (api *HTTPGithub) getPullRequestDataFromCommits(commitChanges github.Commit
 		result = append(result, pr)
 	}
 
-	return result
+	return result, nil
 }
 
-func (api *HTTPGithub) getPullRequestsData(shas []string) []github.PullRequestData {
+func (api *HTTPGithub) getPullRequestsData(ctx context.Context, shas []string) ([]github.PullRequestData, error) {
 	concatenatedSha := strings.Join(shas, "+")
 
-	url := fmt.Sprintf("%s/search/issues?q=repo:ethereum/go-ethereum+is:pr+is:merged+merged+%s", api.config.GithubAPIUrl, concatenatedSha)
-	body, err := api.httpAdapter.DoGet(url)
+	url := fmt.Sprintf("%s/search/issues?q=repo:%s+is:pr+is:merged+merged+%s", api.config.GithubAPIUrl, api.config.GethRepoName, concatenatedSha)
+	items := make([]github.PullRequestData, 0)
+	err := api.httpAdapter.DoGetAllPages(ctx, url, func(body []byte) error {
+		prResult := struct {
+			Items []github.PullRequestData
+		}{}
+		if err := parseJson(body, &prResult); err != nil {
+			return err
+		}
+		items = append(items, prResult.Items...)
+		return nil
+	})
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("search PRs of commits: %w", err)
 	}
 
-	prResult := struct {
-		Items []github.PullRequestData
-	}{}
-	parseJson(body, &prResult)
-
-	return prResult.Items
+	return items, nil
 }
 
-func (api *HTTPGithub) getCommitChanges(base string, target string) github.CommitChanges {
+func (api *HTTPGithub) getCommitChanges(ctx context.Context, base string, target string) (github.CommitChanges, error) {
 	url := fmt.Sprintf("%s/compare/%s...%s", api.config.GethGithubAPIUrl, base, target)
-	body, _ := api.httpAdapter.DoGet(url)
-
 	releaseCompare := github.CommitChanges{}
-	parseJson(body, &releaseCompare)
+	firstPage := true
+	// commits are paginated, the files are only taken from the first page
+	err := api.httpAdapter.DoGetAllPages(ctx, url, func(body []byte) error {
+		page := github.CommitChanges{}
+		if err := parseJson(body, &page); err != nil {
+			return err
+		}
+		releaseCompare.Commits = append(releaseCompare.Commits, page.Commits...)
+		if firstPage {
+			releaseCompare.Files = page.Files
+			firstPage = false
+		}
+		return nil
+	})
+	if err != nil {
+		return github.CommitChanges{}, fmt.Errorf("compare %s...%s: %w", base, target, err)
+	}
 
-	return releaseCompare
+	return releaseCompare, nil
 }
 
-func parseJson(body []byte, data interface{}) {
-	jsonErr := json.Unmarshal(body, data)
-	if jsonErr != nil {
-		log.Fatal(jsonErr)
+func parseJson(body []byte, data interface{}) error {
+	if err := json.Unmarshal(body, data); err != nil {
+		return fmt.Errorf("parse json: %w", err)
 	}
+	return nil
 }
 
 func newReader(data interface{}) (*bytes.Reader, error) {
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// maxPerPage - maximum page size allowed by the GitHub REST API
+const maxPerPage = 100
+
+// linkMatcher - one link of the `Link` header, e.g. <https://api.github.com/...&page=2>; rel="next"
+var linkMatcher = regexp.MustCompile(`<([^>]+)>;\s*rel="([^"]+)"`)
+
+// nextPageURL - URL of the next page given by the `Link` header, empty on the last page
+func nextPageURL(header http.Header) string {
+	for _, link := range linkMatcher.FindAllStringSubmatch(header.Get("Link"), -1) {
+		if link[2] == "next" {
+			return link[1]
+		}
+	}
+	return ""
+}
+
+// withMaxPerPage - request the biggest pages to reduce the number of requests, keeping an explicit per_page
+func withMaxPerPage(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := u.Query()
+	if query.Get("per_page") != "" {
+		return rawURL
+	}
+	query.Set("per_page", strconv.Itoa(maxPerPage))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,108 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"upgradebot/config"
+)
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		link string
+		want string
+	}{
+		{
+			link: `<https://api.github.com/repositories/1/releases?page=2>; rel="next", <https://api.github.com/repositories/1/releases?page=5>; rel="last"`,
+			want: "https://api.github.com/repositories/1/releases?page=2",
+		},
+		{
+			link: `<https://api.github.com/repositories/1/releases?page=4>; rel="prev", <https://api.github.com/repositories/1/releases?page=1>; rel="first"`,
+			want: "",
+		},
+		{link: "", want: ""},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		header.Set("Link", test.link)
+		if got := nextPageURL(header); got != test.want {
+			t.Errorf("nextPageURL(%q) = %q, want %q", test.link, got, test.want)
+		}
+	}
+}
+
+func TestWithMaxPerPage(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://api.github.com/repos/a/b/releases", want: "https://api.github.com/repos/a/b/releases?per_page=100"},
+		{url: "https://api.github.com/repos/a/b/pulls?state=open", want: "https://api.github.com/repos/a/b/pulls?per_page=100&state=open"},
+		{url: "https://api.github.com/repos/a/b/pulls?per_page=5", want: "https://api.github.com/repos/a/b/pulls?per_page=5"},
+	}
+	for _, test := range tests {
+		if got := withMaxPerPage(test.url); got != test.want {
+			t.Errorf("withMaxPerPage(%q) = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
+
+func TestDoGetAllPages(t *testing.T) {
+	const lastPage = 3
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.RequestURI())
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil {
+			page = 1
+		}
+		if page < lastPage {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s/items?per_page=100&page=%d>; rel="next", <http://%s/items?per_page=100&page=%d>; rel="last"`,
+				r.Host, page+1, r.Host, lastPage))
+		}
+		fmt.Fprintf(w, "[%d]", page)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(config.Default())
+	pages := make([]string, 0)
+	err := client.DoGetAllPages(context.Background(), server.URL+"/items", func(body []byte) error {
+		pages = append(pages, string(body))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DoGetAllPages: %v", err)
+	}
+	if want := []string{"[1]", "[2]", "[3]"}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+	if want := []string{"/items?per_page=100", "/items?per_page=100&page=2", "/items?per_page=100&page=3"}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested = %v, want %v", requested, want)
+	}
+}
+
+func TestDoGetAllPagesStopsOnError(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Link", fmt.Sprintf(`<http://%s/items?page=%d>; rel="next"`, r.Host, calls+1))
+		fmt.Fprint(w, "[]")
+	}))
+	defer server.Close()
+
+	stop := fmt.Errorf("stop")
+	err := NewHTTPClient(config.Default()).DoGetAllPages(context.Background(), server.URL+"/items", func(body []byte) error {
+		return stop
+	})
+	if err != stop {
+		t.Errorf("DoGetAllPages = %v, want %v", err, stop)
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+}
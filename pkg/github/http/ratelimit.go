@@ -0,0 +1,176 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"upgradebot/pkg/github"
+)
+
+const (
+	// maxRetries - maximum number of retries of a request that was rate-limited or failed transiently
+	maxRetries = 5
+	// minBackoff and maxBackoff - bounds of the exponential backoff between retries
+	minBackoff = 1 * time.Second
+	maxBackoff = 1 * time.Minute
+	// secondaryRateLimitWait - GitHub recommends to wait at least a minute when a secondary rate limit has no Retry-After
+	secondaryRateLimitWait = 1 * time.Minute
+)
+
+var (
+	// now and sleep - the clock of the retries, replaced in the tests
+	now   = time.Now
+	sleep = sleepContext
+)
+
+// rateLimiter - shares the rate limit state between the requests, so that no request is sent until the limit is reset
+type rateLimiter struct {
+	mu      sync.Mutex
+	resetAt time.Time
+}
+
+// wait - block until the rate limit is reset, if it was exceeded, or until the context is done
+func (r *rateLimiter) wait(ctx context.Context) error {
+	r.mu.Lock()
+	resetAt := r.resetAt
+	r.mu.Unlock()
+
+	d := resetAt.Sub(now())
+	if d <= 0 {
+		return nil
+	}
+	log.Printf("Rate limited, waiting %s until %s\n", d.Round(time.Second), resetAt.Format(time.RFC3339))
+	return sleep(ctx, d)
+}
+
+// block - no request is sent until resetAt
+func (r *rateLimiter) block(resetAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if resetAt.After(r.resetAt) {
+		r.resetAt = resetAt
+	}
+}
+
+// retryDelay - delay before retrying the request, false if it must not be retried.
+// Rate-limited requests are retried whatever the method, as GitHub rejects them before processing them,
+// network errors and 5xx only for idempotent requests.
+// When rate-limited, the wait is shared with all the requests through the rateLimiter, unless the rate limit is only
+// reported by the message of the response, which is retried with backoff.
+func (adapter *HTTPClient) retryDelay(req *http.Request, resp *http.Response, err error, attempt int, idempotent bool) (time.Duration, bool) {
+	if attempt >= maxRetries || req.Context().Err() != nil {
+		return 0, false
+	}
+
+	if err != nil || resp.StatusCode >= http.StatusInternalServerError {
+		if !idempotent {
+			return 0, false
+		}
+		return backoff(attempt), true
+	}
+
+	if isRateLimited(resp) {
+		adapter.rateLimiter.block(rateLimitReset(resp.Header, attempt))
+		return 0, true
+	}
+	if resp.StatusCode == http.StatusForbidden && hasRateLimitMessage(resp) {
+		return backoff(attempt), true
+	}
+	return 0, false
+}
+
+// hasRateLimitMessage - whether the message of the response mentions a rate limit, as a 403 for a secondary rate limit
+// often has neither X-RateLimit-Remaining nor Retry-After. The body is restored to be read again.
+func hasRateLimitMessage(resp *http.Response) bool {
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	var apiErr github.APIError
+	if json.Unmarshal(body, &apiErr) != nil {
+		apiErr.Message = string(body)
+	}
+	return isRateLimitMessage(apiErr.Message)
+}
+
+// isRateLimitMessage - whether the message of an error response mentions a rate limit, e.g.
+// "You have exceeded a secondary rate limit. Please wait a few minutes before you try again."
+func isRateLimitMessage(message string) bool {
+	return strings.Contains(strings.ToLower(message), "rate limit")
+}
+
+// RetryRateLimited - for a rate limit reported in the body of a successful response, as by GraphQL with RATE_LIMITED,
+// block the requests until the reset given by the header of the response, false if the request must not be retried
+func (adapter *HTTPClient) RetryRateLimited(ctx context.Context, header http.Header, attempt int) bool {
+	if attempt >= maxRetries || ctx.Err() != nil {
+		return false
+	}
+	adapter.rateLimiter.block(rateLimitReset(header, attempt))
+	return true
+}
+
+// rateLimitReset - time until which a rate-limited client must wait, from the header of the response
+func rateLimitReset(header http.Header, attempt int) time.Time {
+	if retryAfter, err := strconv.Atoi(header.Get("Retry-After")); err == nil {
+		// secondary rate limit: wait as requested by GitHub
+		return now().Add(time.Duration(retryAfter) * time.Second)
+	}
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil && header.Get("X-RateLimit-Remaining") == "0" {
+		// primary rate limit: wait until the reset of the quota
+		return time.Unix(reset, 0).Add(time.Second)
+	}
+	return now().Add(secondaryRateLimitWait + backoff(attempt))
+}
+
+// sleepContext - sleep for d, or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// backoff - jittered exponential backoff: a random delay between half and all of minBackoff * 2^attempt, capped to maxBackoff
+func backoff(attempt int) time.Duration {
+	d := minBackoff << uint(attempt)
+	if d > maxBackoff || d <= 0 {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// logRateLimit - log the remaining budget of the rate limit
+func logRateLimit(req *http.Request, resp *http.Response, url string) {
+	remaining := resp.Header.Get("X-RateLimit-Remaining")
+	if remaining == "" {
+		log.Printf("%s %s: %d\n", req.Method, url, resp.StatusCode)
+		return
+	}
+	log.Printf("%s %s: %d (rate limit %s: %s/%s remaining)\n", req.Method, url, resp.StatusCode,
+		resp.Header.Get("X-RateLimit-Resource"), remaining, resp.Header.Get("X-RateLimit-Limit"))
+}
+
+// discard - drain and close the body of a response that is not used, so that the connection can be reused
+func discard(resp *http.Response) {
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
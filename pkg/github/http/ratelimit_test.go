@@ -0,0 +1,282 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"upgradebot/config"
+	"upgradebot/pkg/github"
+)
+
+// fakeClock - replace the clock of the retries by one that advances on sleep, returns the recorded waits
+func fakeClock(t *testing.T) *[]time.Duration {
+	current := time.Unix(1700000000, 0)
+	waits := make([]time.Duration, 0)
+	previousNow, previousSleep := now, sleep
+	now = func() time.Time { return current }
+	sleep = func(ctx context.Context, d time.Duration) error {
+		if d > 0 {
+			waits = append(waits, d)
+			current = current.Add(d)
+		}
+		return ctx.Err()
+	}
+	t.Cleanup(func() { now, sleep = previousNow, previousSleep })
+	return &waits
+}
+
+type response struct {
+	status int
+	header map[string]string
+	// message - message of the error body, "failed" by default
+	message string
+}
+
+// newSequenceServer - server answering the given responses in order, then 200
+func newSequenceServer(responses []response) (*httptest.Server, *int) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls > len(responses) {
+			_, _ = w.Write([]byte("{}"))
+			return
+		}
+		for k, v := range responses[calls-1].header {
+			w.Header().Set(k, v)
+		}
+		message := responses[calls-1].message
+		if message == "" {
+			message = "failed"
+		}
+		w.WriteHeader(responses[calls-1].status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": message})
+	}))
+	return server, &calls
+}
+
+func TestRetries(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	serverErrors := make([]response, maxRetries+1)
+	for i := range serverErrors {
+		serverErrors[i] = response{status: http.StatusInternalServerError}
+	}
+	tests := []struct {
+		name   string
+		method string
+		// query - POST with DoQuery
+		query     bool
+		responses []response
+		wantCalls int
+		wantErr   bool
+		// wantWaits - bounds of each wait
+		wantWaits [][2]time.Duration
+	}{
+		{
+			name:      "secondary rate limit with Retry-After",
+			method:    http.MethodGet,
+			responses: []response{{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "7"}}},
+			wantCalls: 2,
+			wantWaits: [][2]time.Duration{{7 * time.Second, 7 * time.Second}},
+		},
+		{
+			name:   "primary rate limit until the reset",
+			method: http.MethodGet,
+			responses: []response{{status: http.StatusForbidden, header: map[string]string{
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(start.Add(30*time.Second).Unix(), 10),
+			}}},
+			wantCalls: 2,
+			wantWaits: [][2]time.Duration{{31 * time.Second, 31 * time.Second}},
+		},
+		{
+			name:      "secondary rate limit without Retry-After",
+			method:    http.MethodGet,
+			responses: []response{{status: http.StatusTooManyRequests}},
+			wantCalls: 2,
+			wantWaits: [][2]time.Duration{{secondaryRateLimitWait + minBackoff/2, secondaryRateLimitWait + minBackoff}},
+		},
+		{
+			name:   "secondary rate limit only in the message",
+			method: http.MethodGet,
+			responses: []response{{status: http.StatusForbidden,
+				message: "You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}},
+			wantCalls: 2,
+			wantWaits: [][2]time.Duration{{minBackoff / 2, minBackoff}},
+		},
+		{
+			name:      "rate-limited POST",
+			method:    http.MethodPost,
+			responses: []response{{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "2"}}},
+			wantCalls: 2,
+			wantWaits: [][2]time.Duration{{2 * time.Second, 2 * time.Second}},
+		},
+		{
+			name:      "forbidden without rate limit",
+			method:    http.MethodGet,
+			responses: []response{{status: http.StatusForbidden, header: map[string]string{"X-RateLimit-Remaining": "10"}}},
+			wantCalls: 1,
+			wantErr:   true,
+			wantWaits: [][2]time.Duration{},
+		},
+		{
+			name:      "server error",
+			method:    http.MethodGet,
+			responses: []response{{status: http.StatusBadGateway}},
+			wantCalls: 2,
+			wantWaits: [][2]time.Duration{{minBackoff / 2, minBackoff}},
+		},
+		{
+			name:      "server errors until the maximum of retries",
+			method:    http.MethodGet,
+			responses: serverErrors,
+			wantCalls: maxRetries + 1,
+			wantErr:   true,
+			wantWaits: [][2]time.Duration{
+				{500 * time.Millisecond, 1 * time.Second},
+				{1 * time.Second, 2 * time.Second},
+				{2 * time.Second, 4 * time.Second},
+				{4 * time.Second, 8 * time.Second},
+				{8 * time.Second, 16 * time.Second},
+			},
+		},
+		{
+			name:      "server error on POST",
+			method:    http.MethodPost,
+			responses: []response{{status: http.StatusBadGateway}},
+			wantCalls: 1,
+			wantErr:   true,
+			wantWaits: [][2]time.Duration{},
+		},
+		{
+			name:      "server error on a query",
+			method:    http.MethodPost,
+			query:     true,
+			responses: []response{{status: http.StatusBadGateway}},
+			wantCalls: 2,
+			wantWaits: [][2]time.Duration{{minBackoff / 2, minBackoff}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			waits := fakeClock(t)
+			server, calls := newSequenceServer(test.responses)
+			defer server.Close()
+
+			client := NewHTTPClient(config.Default())
+			var err error
+			if test.query {
+				_, _, err = client.DoQuery(context.Background(), server.URL, strings.NewReader("{}"))
+			} else if test.method == http.MethodPost {
+				_, err = client.DoPost(context.Background(), server.URL, strings.NewReader("{}"))
+			} else {
+				_, err = client.DoGet(context.Background(), server.URL)
+			}
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want an error: %t", err, test.wantErr)
+			}
+			var apiErr *github.APIError
+			if err != nil && !errors.As(err, &apiErr) {
+				t.Errorf("err = %v, want an APIError", err)
+			}
+			if *calls != test.wantCalls {
+				t.Errorf("calls = %d, want %d", *calls, test.wantCalls)
+			}
+			if len(*waits) != len(test.wantWaits) {
+				t.Fatalf("waits = %v, want %d waits", *waits, len(test.wantWaits))
+			}
+			for i, wait := range *waits {
+				if wait < test.wantWaits[i][0] || wait > test.wantWaits[i][1] {
+					t.Errorf("wait #%d = %s, want between %s and %s", i, wait, test.wantWaits[i][0], test.wantWaits[i][1])
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimitIsShared(t *testing.T) {
+	waits := fakeClock(t)
+	server, calls := newSequenceServer([]response{{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "5"}}})
+	defer server.Close()
+
+	client := NewHTTPClient(config.Default())
+	client.rateLimiter.block(now().Add(10 * time.Second))
+	if _, err := client.DoGet(context.Background(), server.URL); err != nil {
+		t.Fatalf("DoGet: %v", err)
+	}
+	// the first request waits for the block, the retry for the Retry-After
+	if want := []time.Duration{10 * time.Second, 5 * time.Second}; len(*waits) != 2 || (*waits)[0] != want[0] || (*waits)[1] != want[1] {
+		t.Errorf("waits = %v, want %v", *waits, want)
+	}
+	if *calls != 2 {
+		t.Errorf("calls = %d, want 2", *calls)
+	}
+}
+
+func TestRetryRateLimited(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name     string
+		header   http.Header
+		attempt  int
+		want     bool
+		wantWait time.Duration
+	}{
+		{
+			name:     "primary rate limit until the reset",
+			header:   http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {strconv.FormatInt(start.Add(30*time.Second).Unix(), 10)}},
+			want:     true,
+			wantWait: 31 * time.Second,
+		},
+		{
+			name:     "Retry-After",
+			header:   http.Header{"Retry-After": {"7"}},
+			want:     true,
+			wantWait: 7 * time.Second,
+		},
+		{
+			name:    "maximum of retries",
+			header:  http.Header{"Retry-After": {"7"}},
+			attempt: maxRetries,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			waits := fakeClock(t)
+			client := NewHTTPClient(config.Default())
+			if got := client.RetryRateLimited(context.Background(), test.header, test.attempt); got != test.want {
+				t.Fatalf("RetryRateLimited = %t, want %t", got, test.want)
+			}
+			if err := client.rateLimiter.wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if test.want && (len(*waits) != 1 || (*waits)[0] != test.wantWait) {
+				t.Errorf("waits = %v, want %s", *waits, test.wantWait)
+			}
+			if !test.want && len(*waits) != 0 {
+				t.Errorf("waits = %v, want none", *waits)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 0; attempt < 10; attempt++ {
+		max := minBackoff << uint(attempt)
+		if max > maxBackoff {
+			max = maxBackoff
+		}
+		for i := 0; i < 100; i++ {
+			if d := backoff(attempt); d < max/2 || d > max {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", attempt, d, max/2, max)
+			}
+		}
+	}
+}
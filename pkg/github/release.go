@@ -0,0 +1,144 @@
+package github
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/mod/semver"
+)
+
+const PullRequestTitleFormat = "[Upgrade] Go-Ethereum release %s"
+
+// UpgradePullRequestTitle - title of the upgrade PR of a geth tag
+func UpgradePullRequestTitle(tag string) string {
+	return fmt.Sprintf(PullRequestTitleFormat, tag)
+}
+
+// UpgradePolicy - release to upgrade to when no target is given, with the values of the `upgradePolicy` config
+type UpgradePolicy string
+
+const (
+	UpgradePolicyNext                   UpgradePolicy = "next"
+	UpgradePolicyLatestPatchOfNextMinor UpgradePolicy = "latest-patch-of-next-minor"
+	UpgradePolicyLatest                 UpgradePolicy = "latest"
+)
+
+// UpgradeReleases - releases of an upgrade from the geth release merged into Quorum to the target release
+type UpgradeReleases struct {
+	Base   ReleaseData
+	Target ReleaseData
+	// Releases - releases after the base up to the target included, from the oldest to the latest
+	Releases []ReleaseData
+}
+
+// IsUpToDate - whether there is no release to upgrade to
+func (u UpgradeReleases) IsUpToDate() bool {
+	return u.Target.Tag == u.Base.Tag
+}
+
+// SelectUpgrade - get the releases from baseTag to targetTag, or to the release chosen by the policy if targetTag is empty.
+// Releases are ordered by semver, whatever their order on GitHub. Drafts, tags that are not semver and, unless included
+// or explicitly targeted, prereleases are ignored. The target is the base release if there is no release to upgrade to.
+func SelectUpgrade(releases []ReleaseData, baseTag string, targetTag string, policy UpgradePolicy, includePrereleases bool) (UpgradeReleases, error) {
+	base, ok := findRelease(releases, baseTag)
+	if !ok {
+		return UpgradeReleases{}, fmt.Errorf("select upgrade: %w %s", ErrUnknownRelease, baseTag)
+	}
+	if !semver.IsValid(baseTag) {
+		return UpgradeReleases{}, fmt.Errorf("select upgrade: %s is not a semantic version", baseTag)
+	}
+
+	candidates := make([]ReleaseData, 0)
+	for _, r := range releases {
+		if isCandidateRelease(r, includePrereleases) && semver.Compare(r.Tag, baseTag) > 0 {
+			candidates = append(candidates, r)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return semver.Compare(candidates[i].Tag, candidates[j].Tag) < 0
+	})
+
+	var target ReleaseData
+	var err error
+	if targetTag != "" {
+		target, ok = findRelease(releases, targetTag)
+		if !ok || target.Draft {
+			return UpgradeReleases{}, fmt.Errorf("select upgrade: %w %s", ErrUnknownRelease, targetTag)
+		}
+		if !semver.IsValid(targetTag) {
+			return UpgradeReleases{}, fmt.Errorf("select upgrade: %s is not a semantic version", targetTag)
+		}
+		if semver.Compare(targetTag, baseTag) <= 0 {
+			return UpgradeReleases{}, fmt.Errorf("select upgrade: target %s is not greater than %s", targetTag, baseTag)
+		}
+	} else {
+		target, err = policyTarget(base, candidates, policy)
+		if err != nil {
+			return UpgradeReleases{}, fmt.Errorf("select upgrade: %w", err)
+		}
+	}
+
+	upgrade := UpgradeReleases{Base: base, Target: target, Releases: make([]ReleaseData, 0)}
+	if upgrade.IsUpToDate() {
+		return upgrade, nil
+	}
+	for _, r := range candidates {
+		if semver.Compare(r.Tag, target.Tag) < 0 {
+			upgrade.Releases = append(upgrade.Releases, r)
+		}
+	}
+	// the target may be a prerelease explicitly targeted
+	upgrade.Releases = append(upgrade.Releases, target)
+	return upgrade, nil
+}
+
+// policyTarget - release to upgrade to among the candidates greater than the base, ordered by semver
+func policyTarget(base ReleaseData, candidates []ReleaseData, policy UpgradePolicy) (ReleaseData, error) {
+	switch policy {
+	case UpgradePolicyNext, UpgradePolicyLatest, UpgradePolicyLatestPatchOfNextMinor:
+	default:
+		return ReleaseData{}, fmt.Errorf("unknown upgrade policy %q", policy)
+	}
+	if len(candidates) == 0 {
+		return base, nil
+	}
+	switch policy {
+	case UpgradePolicyLatest:
+		return candidates[len(candidates)-1], nil
+	case UpgradePolicyLatestPatchOfNextMinor:
+		// the minor following the base one, or the base one if there is no other minor yet
+		minor := semver.MajorMinor(candidates[0].Tag)
+		for _, r := range candidates {
+			if semver.MajorMinor(r.Tag) != semver.MajorMinor(base.Tag) {
+				minor = semver.MajorMinor(r.Tag)
+				break
+			}
+		}
+		target := candidates[0]
+		for _, r := range candidates {
+			if semver.MajorMinor(r.Tag) == minor {
+				target = r
+			}
+		}
+		return target, nil
+	default:
+		return candidates[0], nil
+	}
+}
+
+// isCandidateRelease - whether an upgrade can target the release
+func isCandidateRelease(r ReleaseData, includePrereleases bool) bool {
+	if r.Draft || !semver.IsValid(r.Tag) {
+		return false
+	}
+	return includePrereleases || (!r.Prerelease && semver.Prerelease(r.Tag) == "")
+}
+
+func findRelease(releases []ReleaseData, tag string) (ReleaseData, bool) {
+	for _, r := range releases {
+		if r.Tag == tag {
+			return r, true
+		}
+	}
+	return ReleaseData{}, false
+}
@@ -0,0 +1,189 @@
+package github
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// testReleases - releases in the order of GitHub, which is not the semver order
+var testReleases = []ReleaseData{
+	{Tag: "v1.11.0"},
+	{Tag: "v1.10.3"},
+	{Tag: "v1.11.0-rc1", Prerelease: true},
+	{Tag: "v1.10.10"},
+	{Tag: "v1.10.2"},
+	{Tag: "v1.10.11", Draft: true},
+	{Tag: "v1.10.1"},
+	{Tag: "not-semver"},
+	{Tag: "v1.10.4", Prerelease: true},
+	{Tag: "v1.9.25"},
+	{Tag: "v1.10.0"},
+}
+
+func tags(releases []ReleaseData) []string {
+	result := make([]string, len(releases))
+	for i, r := range releases {
+		result[i] = r.Tag
+	}
+	return result
+}
+
+func TestSelectUpgrade(t *testing.T) {
+	tests := []struct {
+		name               string
+		base               string
+		target             string
+		policy             UpgradePolicy
+		includePrereleases bool
+		wantTarget         string
+		wantReleases       []string
+	}{
+		{
+			name:         "next",
+			base:         "v1.10.1",
+			policy:       UpgradePolicyNext,
+			wantTarget:   "v1.10.2",
+			wantReleases: []string{"v1.10.2"},
+		},
+		{
+			name:         "latest",
+			base:         "v1.10.1",
+			policy:       UpgradePolicyLatest,
+			wantTarget:   "v1.11.0",
+			wantReleases: []string{"v1.10.2", "v1.10.3", "v1.10.10", "v1.11.0"},
+		},
+		{
+			name:         "latest patch of the next minor",
+			base:         "v1.9.25",
+			policy:       UpgradePolicyLatestPatchOfNextMinor,
+			wantTarget:   "v1.10.10",
+			wantReleases: []string{"v1.10.0", "v1.10.1", "v1.10.2", "v1.10.3", "v1.10.10"},
+		},
+		{
+			name:         "latest patch of the base minor when there is no next minor",
+			base:         "v1.11.0-rc1",
+			policy:       UpgradePolicyLatestPatchOfNextMinor,
+			wantTarget:   "v1.11.0",
+			wantReleases: []string{"v1.11.0"},
+		},
+		{
+			name:               "prereleases included",
+			base:               "v1.10.3",
+			policy:             UpgradePolicyNext,
+			includePrereleases: true,
+			wantTarget:         "v1.10.4",
+			wantReleases:       []string{"v1.10.4"},
+		},
+		{
+			name:               "prerelease tag included",
+			base:               "v1.10.10",
+			policy:             UpgradePolicyNext,
+			includePrereleases: true,
+			wantTarget:         "v1.11.0-rc1",
+			wantReleases:       []string{"v1.11.0-rc1"},
+		},
+		{
+			name:         "explicit target",
+			base:         "v1.10.0",
+			target:       "v1.10.3",
+			policy:       UpgradePolicyLatest,
+			wantTarget:   "v1.10.3",
+			wantReleases: []string{"v1.10.1", "v1.10.2", "v1.10.3"},
+		},
+		{
+			name:         "explicit prerelease target",
+			base:         "v1.10.10",
+			target:       "v1.11.0-rc1",
+			policy:       UpgradePolicyNext,
+			wantTarget:   "v1.11.0-rc1",
+			wantReleases: []string{"v1.11.0-rc1"},
+		},
+		{
+			name:         "up to date",
+			base:         "v1.11.0",
+			policy:       UpgradePolicyLatest,
+			wantTarget:   "v1.11.0",
+			wantReleases: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			upgrade, err := SelectUpgrade(testReleases, test.base, test.target, test.policy, test.includePrereleases)
+			if err != nil {
+				t.Fatalf("SelectUpgrade: %v", err)
+			}
+			if upgrade.Base.Tag != test.base {
+				t.Errorf("base = %s, want %s", upgrade.Base.Tag, test.base)
+			}
+			if upgrade.Target.Tag != test.wantTarget {
+				t.Errorf("target = %s, want %s", upgrade.Target.Tag, test.wantTarget)
+			}
+			if got := tags(upgrade.Releases); !reflect.DeepEqual(got, test.wantReleases) {
+				t.Errorf("releases = %v, want %v", got, test.wantReleases)
+			}
+			if upgrade.IsUpToDate() != (test.wantTarget == test.base) {
+				t.Errorf("IsUpToDate = %t", upgrade.IsUpToDate())
+			}
+		})
+	}
+}
+
+func TestSelectUpgradeErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		base        string
+		target      string
+		policy      UpgradePolicy
+		wantUnknown bool
+	}{
+		{name: "unknown base", base: "v1.8.0", wantUnknown: true},
+		{name: "unknown target", base: "v1.10.0", target: "v1.12.0", wantUnknown: true},
+		{name: "draft target", base: "v1.10.0", target: "v1.10.11", wantUnknown: true},
+		{name: "target equal to the base", base: "v1.10.2", target: "v1.10.2"},
+		{name: "target lower than the base", base: "v1.10.2", target: "v1.10.1"},
+		{name: "base not semver", base: "not-semver"},
+		{name: "target not semver", base: "v1.10.2", target: "not-semver"},
+		{name: "unknown policy", base: "v1.10.2", policy: "previous"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			policy := test.policy
+			if policy == "" {
+				policy = UpgradePolicyNext
+			}
+			_, err := SelectUpgrade(testReleases, test.base, test.target, policy, false)
+			if err == nil {
+				t.Fatal("SelectUpgrade: expected an error")
+			}
+			if errors.Is(err, ErrUnknownRelease) != test.wantUnknown {
+				t.Errorf("SelectUpgrade = %v, want unknown release: %t", err, test.wantUnknown)
+			}
+		})
+	}
+}
+
+func TestPolicyTarget(t *testing.T) {
+	base := ReleaseData{Tag: "v1.9.0"}
+	candidates := []ReleaseData{{Tag: "v1.9.1"}, {Tag: "v1.9.2"}, {Tag: "v1.10.0"}, {Tag: "v1.10.1"}, {Tag: "v1.11.0"}}
+	tests := []struct {
+		policy     UpgradePolicy
+		candidates []ReleaseData
+		want       string
+	}{
+		{policy: UpgradePolicyNext, candidates: candidates, want: "v1.9.1"},
+		{policy: UpgradePolicyLatest, candidates: candidates, want: "v1.11.0"},
+		{policy: UpgradePolicyLatestPatchOfNextMinor, candidates: candidates, want: "v1.10.1"},
+		{policy: UpgradePolicyLatestPatchOfNextMinor, candidates: candidates[:2], want: "v1.9.2"},
+		{policy: UpgradePolicyLatest, candidates: []ReleaseData{}, want: "v1.9.0"},
+	}
+	for _, test := range tests {
+		got, err := policyTarget(base, test.candidates, test.policy)
+		if err != nil {
+			t.Fatalf("policyTarget(%s): %v", test.policy, err)
+		}
+		if got.Tag != test.want {
+			t.Errorf("policyTarget(%s, %v) = %s, want %s", test.policy, tags(test.candidates), got.Tag, test.want)
+		}
+	}
+}
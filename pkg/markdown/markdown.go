@@ -5,9 +5,35 @@ import (
 	"strings"
 
 	"upgradebot/pkg/analysis"
+	"upgradebot/pkg/git"
 	"upgradebot/pkg/github"
 )
 
+// CreatePullRequestBody - create the full body of the upgrade PR: header, versions, release notes of every release of the upgrade and analysis.
+// The version of the Quorum version file is optional.
+func CreatePullRequestBody(upgrade github.UpgradeReleases, version *git.Version, analysis analysis.Analysis) string {
+	builder := strings.Builder{}
+
+	builder.WriteString(CreateMarkdownHeader())
+	builder.WriteString("\n\n")
+	builder.WriteString(CreateMarkdownVersionSection(upgrade.Base.Tag, upgrade.Target.Tag, version))
+	builder.WriteString("\n\n")
+	if len(upgrade.Releases) > 1 {
+		builder.WriteString(CreateMarkdownReleasesSection(upgrade.Releases))
+	} else {
+		builder.WriteString(CreateMarkdownReleaseSection(upgrade.Target))
+	}
+	builder.WriteString("\n\n")
+	if len(analysis.APIBreakages) > 0 {
+		builder.WriteString(CreateMarkdownAPIBreakageSection(analysis.APIBreakages))
+		builder.WriteString("\n\n")
+	}
+	builder.WriteString(CreateMarkdownAnalysisSection(analysis))
+	builder.WriteString("\n\n")
+
+	return builder.String()
+}
+
 func CreateMarkdownHeader() string {
 	builder := strings.Builder{}
 
@@ -39,6 +65,24 @@ func CreateMarkdownHeader() string {
 	return builder.String()
 }
 
+// CreateMarkdownVersionSection - versions of geth before and after the upgrade, and version of Quorum
+func CreateMarkdownVersionSection(baseTag string, targetTag string, version *git.Version) string {
+	builder := strings.Builder{}
+
+	builder.WriteString("## Versions\n\n")
+
+	if version != nil && version.HasQuorumVersion {
+		fmt.Fprintf(&builder, "* GoQuorum: %s\n", version.QuorumVersion())
+	}
+	fmt.Fprintf(&builder, "* Go-Ethereum merged into GoQuorum: %s\n", baseTag)
+	if version != nil {
+		fmt.Fprintf(&builder, "* Go-Ethereum of the GoQuorum version file: %s\n", version.GethVersion())
+	}
+	fmt.Fprintf(&builder, "* Go-Ethereum after the upgrade: %s\n", targetTag)
+
+	return builder.String()
+}
+
 func CreateMarkdownReleaseSection(data github.ReleaseData) string {
 	builder := strings.Builder{}
 
@@ -56,6 +100,114 @@ func CreateMarkdownReleaseSection(data github.ReleaseData) string {
 	return builder.String()
 }
 
+// CreateMarkdownReleasesSection - release notes of each release of an upgrade spanning several releases, from the oldest
+func CreateMarkdownReleasesSection(releases []github.ReleaseData) string {
+	builder := strings.Builder{}
+
+	fmt.Fprintf(&builder, "## Go-Ethereum Releases: %s to %s\n\n", releases[0].Tag, releases[len(releases)-1].Tag)
+
+	for _, data := range releases {
+		fmt.Fprintf(&builder, "* %s\n", data.Tag)
+	}
+
+	for _, data := range releases {
+		builder.WriteString("\n\n")
+		fmt.Fprintf(&builder, "### %s\n\n", data.Name)
+
+		fmt.Fprintf(&builder, "* Version: %s\n", data.Tag)
+		fmt.Fprintf(&builder, "* Published: %s\n", data.PublishedAt)
+
+		builder.WriteString("\n")
+
+		builder.WriteString("#### Release notes \n\n")
+
+		builder.WriteString(data.Body)
+	}
+
+	return builder.String()
+}
+
+// CreateRoadmapReport - summary of every release between the geth version merged into Quorum and the last release
+func CreateRoadmapReport(baseTag string, steps []analysis.RoadmapStep) string {
+	builder := strings.Builder{}
+
+	fmt.Fprintf(&builder, "## Go-Ethereum upgrade roadmap from %s\n\n", baseTag)
+
+	if len(steps) == 0 {
+		builder.WriteString("Already in the latest version.\n")
+		return builder.String()
+	}
+
+	builder.WriteString("Each release is compared to the previous one. Quorum overlap: files of the release changed by Quorum. ")
+	builder.WriteString("Impacted symbols: functions, methods and types changed by the release that Quorum modified or uses. ")
+	builder.WriteString("Conflicts: files of the release with conflicts, and all the files with conflicts when merging up to the release.\n\n")
+
+	builder.WriteString("| Release | Published | PRs | Files changed | Quorum overlap | Impacted symbols | Conflicts | Conflicts merging up to the release |\n")
+	builder.WriteString("| :--- | :--- | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+
+	for _, step := range steps {
+		fmt.Fprintf(&builder, "| %s | %s | %d | %d | %d | %d | %d | %d |\n",
+			step.Release.Tag,
+			step.Release.PublishedAt,
+			step.PullRequestCount,
+			step.ChangedFileCount,
+			step.OverlapCount,
+			step.ImpactedSymbolCount,
+			step.ConflictCount,
+			step.MergeConflictCount)
+	}
+
+	builder.WriteString("\n")
+
+	return builder.String()
+}
+
+// CreateMarkdownAPIBreakageSection - incompatible changes of the go-ethereum API, with the first 10 lines of GoQuorum using each
+func CreateMarkdownAPIBreakageSection(breakages []analysis.APIBreakage) string {
+	builder := strings.Builder{}
+
+	builder.WriteString("## Breaking API changes affecting GoQuorum\n\n")
+
+	builder.WriteString("Exported declarations removed or changed incompatibly, used by files added by GoQuorum (matched by name, without type checking).\n\n")
+
+	builder.WriteString("| Declaration | Change | Used in |\n")
+	builder.WriteString("| :--- | :--- | :--- |\n")
+
+	for _, breakage := range breakages {
+		fmt.Fprintf(&builder, "| ``%s`` | %s | %s |\n",
+			breakage.Name,
+			createMarkdownAPIChange(breakage),
+			createMarkdownAPIUsages(breakage.Usages))
+	}
+
+	return builder.String()
+}
+
+func createMarkdownAPIChange(breakage analysis.APIBreakage) string {
+	switch {
+	case breakage.Target == "":
+		return fmt.Sprintf("removed: ``%s``", breakage.Base)
+	case breakage.Base == "":
+		return fmt.Sprintf("added to the interface: ``%s``", breakage.Target)
+	default:
+		return fmt.Sprintf("``%s``<br>➡️ ``%s``", breakage.Base, breakage.Target)
+	}
+}
+
+func createMarkdownAPIUsages(usages []analysis.APIUsage) string {
+	builder := strings.Builder{}
+
+	for i, usage := range usages {
+		if i == 10 {
+			fmt.Fprintf(&builder, "and %d more", len(usages)-i)
+			break
+		}
+		fmt.Fprintf(&builder, "``%s:%d``<br>", usage.File, usage.Line)
+	}
+
+	return builder.String()
+}
+
 func CreateMarkdownAnalysisSection(analysis analysis.Analysis) string {
 	builder := strings.Builder{}
 
@@ -65,9 +217,15 @@ func CreateMarkdownAnalysisSection(analysis analysis.Analysis) string {
 
 	builder.WriteString("File Stats: (A) Added, (M) Modified and (R) Removed\n\n")
 	builder.WriteString("Line Stats: (A) Added and (R) Removed\n\n")
+	builder.WriteString("Hunks: (O) upstream hunks overlapping GoQuorum changes and (A) adjacent to them\n\n")
+	builder.WriteString("Impacted symbols: functions, methods and types changed by the PR that GoQuorum (M) modified or (U: n) uses from n of its own files\n\n")
+
+	builder.WriteString("Affected critical areas: areas of the code risky for GoQuorum changed by the PR, with their criticality and owner. Any change of a high criticality area requires a review.\n\n")
+	builder.WriteString("Risk: score of the PR or file, weighting its lines changed, files changed by GoQuorum too, conflicts, hunks, impacted symbols and critical areas. PRs and files are sorted from the riskiest.\n\n")
 
 	builder.WriteString("Assessment:\n\n")
 	builder.WriteString("* ✅ No conflict expected\n")
+	builder.WriteString("* ℹ️ Changed by GoQuorum too, in other regions of the file\n")
 	builder.WriteString("* ⚠ Review required to assess changes\n")
 	builder.WriteString("* ‼️ Conflicts expected and review required\n")
 
@@ -77,18 +235,22 @@ func CreateMarkdownAnalysisSection(analysis analysis.Analysis) string {
 
 	builder.WriteString("\n\n")
 
-	builder.WriteString("| 🔍 | Link | Title | File Stats<br>M/A/R | Packages changed<br>(files changed) | Line Stats<br>A/R | Top 5 Changed Files<br>(lines changed) |\n")
-	builder.WriteString("| :--- | :--- | :--- | :--- | :--- | :--- | :--- |\n")
+	builder.WriteString("| 🔍 Risk | Link | Title | File Stats<br>M/A/R | Packages changed<br>(files changed) | Line Stats<br>A/R | Hunks<br>O/A | Impacted symbols | Affected critical areas | Top 5 Changed Files<br>(lines changed) |\n")
+	builder.WriteString("| :--- | :--- | :--- | :--- | :--- | :--- | :--- | :--- | :--- | :--- |\n")
 
 	for _, stats := range analysis.PrStats {
-		fmt.Fprintf(&builder, "| %s | [#%d](%s) | ``%s`` | %s | %s | %s | %s |\n",
+		fmt.Fprintf(&builder, "| %s %.1f | [#%d](%s) | ``%s`` | %s | %s | %s | %s | %s | %s | %s |\n",
 			getAssessmentEmoji(stats.Assessment),
+			stats.Risk,
 			stats.Data.Number,
 			stats.Data.HtmlUrl,
 			stats.Data.Title,
 			createMarkdownPullRequestFileStats(stats),
 			createMarkdownPullRequestPackageChangedStats(stats),
 			createMarkdownPullRequestLineStats(stats),
+			createMarkdownHunkOverlap(stats.Overlap),
+			createMarkdownPullRequestSymbolStats(stats),
+			createMarkdownPullRequestCriticalAreaStats(stats),
 			createMarkdownPullRequestTopChangedStats(stats))
 	}
 
@@ -96,14 +258,16 @@ func CreateMarkdownAnalysisSection(analysis analysis.Analysis) string {
 
 	fmt.Fprintf(&builder, "### %d Changed files\n\n", len(analysis.FileStats))
 
-	builder.WriteString("| 🔍 | File | Lines Changed | Linked PR |\n")
-	builder.WriteString("| :--- | :--- | :--- | :--- |\n")
+	builder.WriteString("| 🔍 Risk | File | Lines Changed | Hunks<br>O/A | Linked PR |\n")
+	builder.WriteString("| :--- | :--- | :--- | :--- | :--- |\n")
 
 	for _, stat := range analysis.FileStats {
-		fmt.Fprintf(&builder, "| %s | ``%s`` | %d | %s |\n",
+		fmt.Fprintf(&builder, "| %s %.1f | ``%s`` | %d | %s | %s |\n",
 			getAssessmentEmoji(stat.Assessment),
+			stat.Risk,
 			stat.File.Filename,
 			stat.File.GetTotalModifications(),
+			createMarkdownHunkOverlap(stat.Overlap),
 			createMarkdownPullRequestDataListStats(stat.AssociatedPRs))
 	}
 
@@ -138,6 +302,46 @@ func createMarkdownPullRequestLineStats(stats analysis.PullRequestStats) string
 	return builder.String()
 }
 
+func createMarkdownHunkOverlap(overlap analysis.HunkOverlap) string {
+	return fmt.Sprintf("%d/%d", overlap.Overlapping, overlap.Adjacent)
+}
+
+// createMarkdownPullRequestSymbolStats - first 10 impacted symbols of a PR, with the number of GoQuorum files using them
+func createMarkdownPullRequestSymbolStats(stats analysis.PullRequestStats) string {
+	builder := strings.Builder{}
+
+	for i, symbol := range stats.Symbols {
+		if i == 10 {
+			fmt.Fprintf(&builder, "and %d more", len(stats.Symbols)-i)
+			break
+		}
+		fmt.Fprintf(&builder, "``%s``", symbol.Name)
+		if symbol.ModifiedByQuorum {
+			builder.WriteString(" (M)")
+		}
+		if len(symbol.QuorumFiles) > 0 {
+			fmt.Fprintf(&builder, " (U: %d)", len(symbol.QuorumFiles))
+		}
+		builder.WriteString("<br>")
+	}
+
+	return builder.String()
+}
+
+func createMarkdownPullRequestCriticalAreaStats(stats analysis.PullRequestStats) string {
+	builder := strings.Builder{}
+
+	for _, area := range stats.CriticalAreas {
+		fmt.Fprintf(&builder, "``%s`` (%s", area.Path, area.Criticality)
+		if area.Owner != "" {
+			fmt.Fprintf(&builder, ", %s", area.Owner)
+		}
+		builder.WriteString(")<br>")
+	}
+
+	return builder.String()
+}
+
 func createMarkdownPullRequestPackageChangedStats(stats analysis.PullRequestStats) string {
 	builder := strings.Builder{}
 
@@ -156,6 +360,8 @@ func getAssessmentEmoji(assessment analysis.Assessment) string {
 		return "‼️"
 	case analysis.Warning:
 		return "⚠️"
+	case analysis.SameFile:
+		return "ℹ️"
 	default:
 		return "✅"
 	}
@@ -0,0 +1,60 @@
+package workerpool
+
+import (
+	"context"
+	"sync"
+)
+
+// Run - call fn for every index in [0, count) on at most `workers` goroutines.
+// The first error cancels the context given to the other calls and is returned once all the goroutines are done.
+// Callers keep a deterministic output by storing the result of each call at its index.
+func Run(ctx context.Context, workers int, count int, fn func(ctx context.Context, i int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > count {
+		workers = count
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indexes := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := fn(ctx, i); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	func() {
+		defer close(indexes)
+		for i := 0; i < count; i++ {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
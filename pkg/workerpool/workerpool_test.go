@@ -0,0 +1,114 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunStoresResultsByIndex(t *testing.T) {
+	for _, workers := range []int{0, 1, 4, 100} {
+		const count = 50
+		results := make([]int, count)
+		var running, maxRunning int32
+		err := Run(context.Background(), workers, count, func(ctx context.Context, i int) error {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			// the later indexes finish first
+			time.Sleep(time.Duration(count-i) * 10 * time.Microsecond)
+			results[i] = i * i
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Run with %d workers: %v", workers, err)
+		}
+
+		want := make([]int, count)
+		for i := range want {
+			want[i] = i * i
+		}
+		if !reflect.DeepEqual(results, want) {
+			t.Errorf("results with %d workers = %v, want %v", workers, results, want)
+		}
+		limit := int32(workers)
+		if limit < 1 {
+			limit = 1
+		}
+		if maxRunning > limit {
+			t.Errorf("%d calls ran at once with %d workers", maxRunning, workers)
+		}
+	}
+}
+
+func TestRunReturnsFirstError(t *testing.T) {
+	failure := errors.New("failure")
+	var calls int32
+	err := Run(context.Background(), 1, 10, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		if i == 3 {
+			return failure
+		}
+		return nil
+	})
+	if err != failure {
+		t.Errorf("Run = %v, want %v", err, failure)
+	}
+	// no index is given to the worker once the context is cancelled
+	if calls != 4 {
+		t.Errorf("calls = %d, want 4", calls)
+	}
+}
+
+func TestRunCancelsOtherCalls(t *testing.T) {
+	failure := errors.New("failure")
+	err := Run(context.Background(), 2, 2, func(ctx context.Context, i int) error {
+		if i == 0 {
+			return failure
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+			t.Error("the context of the other call was not cancelled")
+			return nil
+		}
+	})
+	if err != failure {
+		t.Errorf("Run = %v, want %v", err, failure)
+	}
+}
+
+func TestRunWithCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls int32
+	err := Run(ctx, 4, 100, func(ctx context.Context, i int) error {
+		atomic.AddInt32(&calls, 1)
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run = %v, want %v", err, context.Canceled)
+	}
+	if calls == 100 {
+		t.Errorf("all the calls ran with a cancelled context")
+	}
+}
+
+func TestRunWithoutWork(t *testing.T) {
+	err := Run(context.Background(), 4, 0, func(ctx context.Context, i int) error {
+		t.Errorf("unexpected call %d", i)
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Run = %v, want nil", err)
+	}
+}